@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/o0n1x/chirpy/internal/api"
+	"github.com/o0n1x/chirpy/internal/auth"
+)
+
+const usage = `usage:
+  chirpy                                   start the server
+  chirpy keys list                         show the access-token signing keys
+  chirpy keys rotate [-alg EdDSA] [-delay 5m]
+                                           add a key that starts signing after the delay
+  chirpy keys verify-only <kid>            stop signing with a key but keep accepting it
+  chirpy keys retire <kid>                 stop accepting tokens signed with a key
+  chirpy keys reseal                       encrypt the stored keys with SIGNING_KEY_ENCRYPTION_KEY
+  chirpy promote-admin <email>             make an existing account an admin
+  chirpy calibrate-hash [-target 500ms] [-memory 65536] [-parallelism 4]
+                                           pick password hash parameters for this host`
+
+// runCommand handles the maintenance subcommands.
+func runCommand(cfg *api.ApiConfig, args []string) error {
+	switch args[0] {
+	case "keys":
+		return runKeysCommand(cfg, args[1:])
+	case "promote-admin":
+		if len(args) != 2 {
+			return fmt.Errorf("%s", usage)
+		}
+		user, err := cfg.PromoteUser(context.Background(), args[1], auth.RoleAdmin)
+		if err != nil {
+			return fmt.Errorf("promoting %s: %w", args[1], err)
+		}
+		fmt.Printf("%s (%s) is now an admin, from their next login or token refresh\n", user.Email, user.ID)
+		return nil
+	case "calibrate-hash":
+		return runCalibrateHashCommand(cfg, args[1:])
+	}
+	return fmt.Errorf("%s", usage)
+}
+
+// runKeysCommand manages the signing keys. Running servers pick up changes on
+// their next keyring reload, within a minute.
+func runKeysCommand(cfg *api.ApiConfig, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%s", usage)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "list":
+		rows, err := cfg.DB.GetSigningKeys(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "KID\tALG\tSTATUS\tACTIVATES AT")
+		for _, row := range rows {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", row.ID, row.Alg, row.Status, row.ActivatesAt.UTC().Format(time.RFC3339))
+		}
+		return tw.Flush()
+
+	case "rotate":
+		fs := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+		alg := fs.String("alg", auth.AlgEdDSA, "signing algorithm: HS256, RS256 or EdDSA")
+		// longer than the keyring reload interval and the JWKS cache lifetime
+		delay := fs.Duration("delay", 5*time.Minute, "how long to publish the key before signing with it")
+		err := fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		key, err := cfg.RotateSigningKey(ctx, *alg, *delay)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added %s key %s, signing from %s\n", key.Alg, key.ID, key.ActivatesAt.UTC().Format(time.RFC3339))
+		fmt.Println("retire the previous key once its tokens have expired, an hour after that")
+		return nil
+
+	case "verify-only", "retire":
+		if len(args) != 2 {
+			return fmt.Errorf("%s", usage)
+		}
+		status := auth.KeyVerifyOnly
+		if args[0] == "retire" {
+			status = auth.KeyRetired
+		}
+		err := cfg.SetSigningKeyStatus(ctx, args[1], status)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("key %s is now %s\n", args[1], status)
+		return nil
+
+	case "reseal":
+		resealed, err := cfg.ResealSigningKeys(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("resealed %d keys\n", resealed)
+		return nil
+	}
+	return fmt.Errorf("%s", usage)
+}
+
+// runCalibrateHashCommand times password hashes on this host and prints the
+// settings that come closest to the target without going over it.
+func runCalibrateHashCommand(cfg *api.ApiConfig, args []string) error {
+	fs := flag.NewFlagSet("calibrate-hash", flag.ContinueOnError)
+	target := fs.Duration("target", 500*time.Millisecond, "how long one password hash should take")
+	memory := fs.Uint("memory", uint(cfg.PasswordHashParams.Memory), "memory per hash in KiB; lowered if one pass is slower than the target")
+	parallelism := fs.Uint("parallelism", uint(cfg.PasswordHashParams.Parallelism), "lanes per hash")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *memory > math.MaxUint32 || *parallelism > math.MaxUint8 {
+		return fmt.Errorf("memory or parallelism is out of range")
+	}
+
+	fmt.Printf("timing argon2id hashes, aiming for %s...\n", *target)
+	params, elapsed, err := auth.CalibrateHashParams(*target, uint32(*memory), uint8(*parallelism))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s takes %s per hash; set:\n\n", params, elapsed.Round(time.Millisecond))
+	fmt.Printf("PASSWORD_HASH_MEMORY=%d\nPASSWORD_HASH_ITERATIONS=%d\nPASSWORD_HASH_PARALLELISM=%d\n\n", params.Memory, params.Iterations, params.Parallelism)
+	if elapsed > *target {
+		fmt.Println("even the smallest setting tried is slower than the target on this host")
+	}
+	fmt.Println("existing passwords are rehashed with the new settings as their owners log in")
+	return nil
+}
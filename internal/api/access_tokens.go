@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+const accessTokenTTL = time.Hour
+
+// RunDenylistSyncer copies access tokens revoked by other servers into the
+// in-memory denylist every interval, and drops entries for expired tokens.
+// The first pass loads every revocation that still matters.
+func (cfg *ApiConfig) RunDenylistSyncer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	since := time.Time{}
+	for {
+		revoked, err := cfg.DB.GetRevokedJTIsSince(ctx, since)
+		if err != nil {
+			log.Printf("Error syncing revoked access tokens: %v", err)
+		}
+		for _, row := range revoked {
+			cfg.Keyring.Denylist.Add(row.Jti.String(), row.ExpiresAt)
+			// rows written by a transaction that commits late carry its start time,
+			// so the next pass looks back a little further than the newest row
+			if row.RevokedAt.Add(-time.Minute).After(since) {
+				since = row.RevokedAt.Add(-time.Minute)
+			}
+		}
+		cfg.Keyring.Denylist.Prune(time.Now())
+		err = cfg.DB.DeleteExpiredRevokedJTIs(ctx)
+		if err != nil {
+			log.Printf("Error cleaning up revoked access tokens: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// denylist applies revocations on this server straight away; other servers
+// pick them up from the database on their next sync.
+func (cfg *ApiConfig) denylist(revoked []database.RevokedJti) {
+	for _, row := range revoked {
+		cfg.Keyring.Denylist.Add(row.Jti.String(), row.ExpiresAt)
+	}
+}
+
+// revokeUserAccessTokens ends every unexpired access token issued to the user.
+func (cfg *ApiConfig) revokeUserAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	revoked, err := cfg.DB.RevokeUserAccessTokens(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return err
+	}
+	cfg.denylist(revoked)
+	return nil
+}
+
+// revokeFamilyAccessTokens ends the access tokens issued to one session.
+func (cfg *ApiConfig) revokeFamilyAccessTokens(ctx context.Context, familyID uuid.UUID) error {
+	revoked, err := cfg.DB.RevokeFamilyAccessTokens(ctx, familyID)
+	if err != nil {
+		return err
+	}
+	cfg.denylist(revoked)
+	return nil
+}
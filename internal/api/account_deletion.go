@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+	"github.com/o0n1x/chirpy/internal/mail"
+)
+
+// RunAccountDeletionWorker purges accounts whose deletion grace period has passed.
+// Deleting the user cascades to their chirps, tokens and other data; only an
+// anonymized row in deleted_users is kept.
+func (cfg *ApiConfig) RunAccountDeletionWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := cfg.purgeDeletedAccounts(ctx)
+		if err != nil {
+			log.Printf("Error purging deleted accounts: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *ApiConfig) purgeDeletedAccounts(ctx context.Context) error {
+	cutoff := time.Now().Add(-cfg.DeletionGracePeriod)
+	users, err := cfg.DB.GetUsersDueForDeletion(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	// one account that can't be purged mustn't hold up the rest
+	failures := []error{}
+	for _, user := range users {
+		purged, err := cfg.purgeAccount(ctx, user, cutoff)
+		if purged {
+			log.Printf("purged account %s", user.ID)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Errorf("purging user %s: %w", user.ID, err))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// purgeAccount deletes user if their deletion is still due at cutoff. It
+// reports false when they logged in and cancelled it since the list was read.
+func (cfg *ApiConfig) purgeAccount(ctx context.Context, user database.User, cutoff time.Time) (bool, error) {
+	// export archives live on disk, so they don't go away with the cascade
+	exports, err := cfg.DB.GetDataExportsForUser(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	err = qtx.CreateDeletedUser(ctx, database.CreateDeletedUserParams{
+		ID:                  user.ID,
+		AccountCreatedAt:    user.CreatedAt,
+		DeletionRequestedAt: user.DeletionRequestedAt.Time,
+	})
+	if err != nil {
+		return false, err
+	}
+	deleted, err := qtx.PurgeUserDueForDeletion(ctx, database.PurgeUserDueForDeletionParams{
+		ID:     user.ID,
+		Cutoff: cutoff,
+	})
+	if err != nil {
+		return false, err
+	}
+	if deleted == 0 {
+		return false, nil
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	// the files only go once the account is gone for good
+	failures := []error{}
+	for _, export := range exports {
+		err = removeExportFile(export)
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return true, errors.Join(failures...)
+}
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	if !cfg.confirmPassword(w, r, user, params.Password) {
+		return
+	}
+
+	user, err = cfg.DB.ScheduleUserDeletion(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error scheduling deletion: %v", err)
+		respondWithError(w, 500, "Failed to schedule deletion")
+		return
+	}
+	// logging in again is how the deletion gets cancelled, so end every current session
+	err = cfg.DB.RevokeUserRefreshTokens(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		log.Printf("Error revoking refresh tokens: %v", err)
+	}
+	err = cfg.revokeUserAccessTokens(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+	}
+	err = cfg.DB.RevokeUserPersonalAccessTokens(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error revoking personal access tokens: %v", err)
+	}
+
+	deleteAt := user.DeletionRequestedAt.Time.Add(cfg.DeletionGracePeriod)
+	go cfg.sendDeletionScheduledEmail(user.Email, deleteAt)
+
+	respondWithJSON(w, 202, struct {
+		DeleteAt time.Time `json:"delete_at"`
+	}{
+		DeleteAt: deleteAt,
+	})
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+func (cfg *ApiConfig) sendDeletionScheduledEmail(email string, deleteAt time.Time) {
+	err := cfg.Mailer.Send(context.Background(), mail.Message{
+		To:      email,
+		Subject: "Your Chirpy account will be deleted",
+		Body: fmt.Sprintf("Your Chirpy account and all of its chirps will be deleted on %s.\n\n"+
+			"Changed your mind? Log in before then and the deletion will be cancelled.\n", deleteAt.UTC().Format(time.RFC1123)),
+	})
+	if err != nil {
+		log.Printf("Error sending deletion email: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+type actorKey struct{}
+
+//
+// HANDLERS
+//
+
+// SetUserRole is mounted behind RequireRole(auth.RoleAdmin).
+func (cfg *ApiConfig) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	userUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	if !auth.ValidRole(params.Role) {
+		respondWithFieldErrors(w, map[string]string{"role": "role must be user, moderator or admin"})
+		return
+	}
+	// the last admin demoting themselves would leave nobody to promote anyone
+	if userUUID == actorFromContext(r.Context()) && params.Role != auth.RoleAdmin {
+		respondWithError(w, 400, "admins can't demote themselves")
+		return
+	}
+
+	user, err := cfg.DB.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userUUID,
+		Role: params.Role,
+	})
+	if err != nil {
+		log.Printf("Error setting role: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	// outstanding access tokens carry the old role
+	err = cfg.revokeUserAccessTokens(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+	}
+	log.Printf("user %s given role %s by %s", user.ID, user.Role, actorFromContext(r.Context()))
+
+	respondWithJSON(w, 200, struct {
+		ID   uuid.UUID `json:"id"`
+		Role string    `json:"role"`
+	}{
+		ID:   user.ID,
+		Role: user.Role,
+	})
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// RequireRole only passes requests whose access token carries role or a higher one.
+// Personal access tokens never have a role, so they can't reach these routes.
+func (cfg *ApiConfig) RequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			log.Printf("Error parsing header: %v", err)
+			respondWithError(w, 401, "Token missing or invalid")
+			return
+		}
+		claims, err := auth.ParseAccessToken(token, cfg.Keyring)
+		if err != nil {
+			log.Printf("Error validating token: %v", err)
+			respondWithError(w, 401, "Token missing or invalid")
+			return
+		}
+		if !auth.RoleAtLeast(claims.Role, role) {
+			log.Printf("user %s with role %q denied %s %s", claims.Subject, claims.Role, r.Method, r.URL.Path)
+			respondWithError(w, 403, "requires the "+role+" role")
+			return
+		}
+		actor, err := uuid.Parse(claims.Subject)
+		if err != nil {
+			log.Printf("Error validating token: %v", err)
+			respondWithError(w, 401, "Token missing or invalid")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+	})
+}
+
+// actorFromContext is the user RequireRole let through.
+func actorFromContext(ctx context.Context) uuid.UUID {
+	actor, _ := ctx.Value(actorKey{}).(uuid.UUID)
+	return actor
+}
+
+// PromoteUser gives the account with email a role. It backs `chirpy promote-admin`,
+// which is how the first admin is made.
+func (cfg *ApiConfig) PromoteUser(ctx context.Context, email, role string) (database.User, error) {
+	user, err := cfg.DB.SetUserRoleByEmail(ctx, database.SetUserRoleByEmailParams{
+		Email: normalizeEmail(email),
+		Role:  role,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, cfg.revokeUserAccessTokens(ctx, user.ID)
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -15,22 +17,44 @@ import (
 	"github.com/google/uuid"
 	"github.com/o0n1x/chirpy/internal/auth"
 	"github.com/o0n1x/chirpy/internal/database"
+	"github.com/o0n1x/chirpy/internal/mail"
+	"github.com/o0n1x/chirpy/internal/oidc"
 )
 
 type ApiConfig struct {
 	FileserverHits atomic.Int32
 	DB             *database.Queries
+	DBConn         *sql.DB
 	Platform       string
 	SECRET_JWT     string
-	PolkaKey       string
+	// keys that sign and verify access tokens; SECRET_JWT still signs single-purpose tokens
+	Keyring *auth.Keyring
+	// encrypts the private keys in signing_keys, so SECRET_JWT can change without them
+	KeyEncryptionKey string
+	PolkaKey         string
+	Notifier         *Notifier
+	Mailer           mail.Mailer
+	BaseURL          string
+	// actions such as ActionChirps that need a verified email address
+	VerifiedEmailRequired map[string]bool
+	DeletionGracePeriod   time.Duration
+	// where data export archives are written until their download link expires
+	ExportDir      string
+	PasswordPolicy auth.PasswordPolicy
+	// costs for new password hashes; older hashes are upgraded at login
+	PasswordHashParams auth.HashParams
+	// nil unless an external OpenID Connect provider is configured for login
+	OIDC *oidc.Provider
 }
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	IsProtected   bool      `json:"is_protected"`
+	EmailVerified bool      `json:"email_verified"`
 }
 
 type Chirp struct {
@@ -51,7 +75,16 @@ func Healthz(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// JWKS publishes the public access-token keys so other services can verify tokens.
+func (cfg *ApiConfig) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, 200, cfg.Keyring.JWKS())
+}
+
 func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
+	// chirps from protected accounts are only visible to approved followers
+	viewer := cfg.viewerFromRequest(r)
+
 	chirpID := r.PathValue("id")
 	if chirpID != "" {
 		chirpUUID, err := uuid.Parse(chirpID)
@@ -60,7 +93,10 @@ func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, 400, "invalid ID")
 			return
 		}
-		chirp, err := cfg.DB.GetChirp(r.Context(), chirpUUID)
+		chirp, err := cfg.DB.GetChirp(r.Context(), database.GetChirpParams{
+			ID:       chirpUUID,
+			ViewerID: viewer,
+		})
 		if err != nil {
 			log.Printf("Error retrieving chirp: %v", err)
 			respondWithError(w, 404, "chirp not found")
@@ -78,6 +114,7 @@ func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var chirps []database.Chirp
+	var err error
 
 	author_id := r.URL.Query().Get("author_id")
 	if author_id != "" {
@@ -87,7 +124,10 @@ func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, 400, "invalid author ID")
 			return
 		}
-		chirps, err = cfg.DB.GetChirpsByAuthor(r.Context(), uuid.NullUUID{UUID: userUUID, Valid: true})
+		chirps, err = cfg.DB.GetChirpsByAuthor(r.Context(), database.GetChirpsByAuthorParams{
+			UserID:   uuid.NullUUID{UUID: userUUID, Valid: true},
+			ViewerID: viewer,
+		})
 		if err != nil {
 			log.Printf("Error retrieving chirp: %v", err)
 			respondWithError(w, 404, "chirp not found")
@@ -95,8 +135,7 @@ func (cfg *ApiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 		}
 
 	} else {
-		var err error
-		chirps, err = cfg.DB.GetChirps(r.Context())
+		chirps, err = cfg.DB.GetChirps(r.Context(), viewer)
 		if err != nil {
 			log.Printf("Error retrieving chirps: %v", err)
 			respondWithError(w, 500, "Failed to retrieve chirps")
@@ -131,16 +170,8 @@ func (cfg *ApiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error parsing header: %v", err)
-		respondWithError(w, 401, "Token missing or invalid")
-		return
-	}
-	userid, err := auth.ValidateJWT(token, cfg.SECRET_JWT)
-	if err != nil {
-		log.Printf("Error validating token: %v", err)
-		respondWithError(w, 401, "Token missing or invalid")
+	userid, ok := cfg.authorize(w, r, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
 
@@ -150,7 +181,10 @@ func (cfg *ApiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 400, "invalid ID")
 		return
 	}
-	chirp, err := cfg.DB.GetChirp(r.Context(), chirpUUID)
+	chirp, err := cfg.DB.GetChirp(r.Context(), database.GetChirpParams{
+		ID:       chirpUUID,
+		ViewerID: userid,
+	})
 	if err != nil {
 		log.Printf("Error retrieving chirp: %v", err)
 		respondWithError(w, 404, "chirp not found")
@@ -201,39 +235,29 @@ func (cfg *ApiConfig) CreateChirp(w http.ResponseWriter, r *http.Request) {
 		Body string `json:"body"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error parsing header: %v", err)
-		respondWithError(w, 401, "Token missing or invalid")
+	userid, ok := cfg.authorize(w, r, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
-	userid, err := auth.ValidateJWT(token, cfg.SECRET_JWT)
-	if err != nil {
-		log.Printf("Error validating token: %v", err)
-		respondWithError(w, 401, "Token missing or invalid")
+
+	if !cfg.requireVerifiedEmail(w, r, userid, ActionChirps) {
 		return
 	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err := decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding parameters: %s", err)
 		respondWithError(w, 400, "Invalid JSON in the request body")
 		return
 	}
-	if len(params.Body) == 0 {
-		respondWithError(w, 400, "Body is required")
-		return
-	}
-
-	isvalid := len(params.Body) <= 140
-	if !isvalid {
-		respondWithError(w, 400, "Chirp is too long")
+	params.Body, err = validateBody(params.Body, "Chirp")
+	if err != nil {
+		respondWithError(w, 400, err.Error())
 		return
 	}
 
-	params.Body = cleanifyString(params.Body)
 	chirp, err := cfg.DB.CreateChirp(r.Context(), database.CreateChirpParams{
 		Body:   params.Body,
 		UserID: uuid.NullUUID{UUID: userid, Valid: true},
@@ -267,7 +291,13 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 400, "Invalid JSON in the request body")
 		return
 	}
-	hashedpass, err := auth.HashPassword(params.Password)
+	params.Email = normalizeEmail(params.Email)
+	fields := cfg.validateUserFields(&params.Email, &params.Password)
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, fields)
+		return
+	}
+	hashedpass, err := auth.HashPassword(params.Password, cfg.PasswordHashParams)
 	if err != nil {
 		log.Printf("Error creating user: %v", err)
 		respondWithError(w, 500, "Failed to hash password")
@@ -277,35 +307,46 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		Email:          params.Email,
 		HashedPassword: sql.NullString{String: hashedpass, Valid: true},
 	})
+	if isUniqueViolation(err) {
+		respondWithFieldErrors(w, map[string]string{"email": "email is already registered"})
+		return
+	}
 	if err != nil {
 		log.Printf("Error creating user: %v", err)
 		respondWithError(w, 500, "Failed to create user")
 		return
 	}
+	go cfg.sendVerificationEmail(user)
 
 	respondWithJSON(w, 201, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   cfg.chirpyRedActive(user),
+		IsProtected:   user.IsProtected,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	})
 
 }
 
+// UpdateUser replaces both the email and the password. It is PatchUser with
+// both fields required, so it needs current_password and has the same effects.
 func (cfg *ApiConfig) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password        string `json:"password"`
+		Email           string `json:"email"`
+		CurrentPassword string `json:"current_password"`
 	}
 
+	// email and password changes need a login JWT; scoped tokens never reach them
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		log.Printf("Error parsing header: %v", err)
 		respondWithError(w, 401, "Token missing or invalid")
 		return
 	}
-	userid, err := auth.ValidateJWT(token, cfg.SECRET_JWT)
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
 	if err != nil {
 		log.Printf("Error validating token: %v", err)
 		respondWithError(w, 401, "Token missing or invalid")
@@ -319,31 +360,190 @@ func (cfg *ApiConfig) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 400, "Invalid JSON in the request body")
 		return
 	}
-	hashedpass, err := auth.HashPassword(params.Password)
+	// PUT replaces both fields; PATCH /api/users/me is for changing just one
+	params.Email = normalizeEmail(params.Email)
+	fields := cfg.validateUserFields(&params.Email, &params.Password)
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, fields)
+		return
+	}
+	cfg.changeCredentials(w, r, userid, &params.Email, &params.Password, params.CurrentPassword)
+}
+
+func (cfg *ApiConfig) PatchUser(w http.ResponseWriter, r *http.Request) {
+	// nil means the field was left out and stays as it is
+	type parameters struct {
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+	}
+
+	// email and password changes need a login JWT; scoped tokens never reach them
+	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		log.Printf("Error creating user: %v", err)
-		respondWithError(w, 500, "Failed to hash password")
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	if params.Email == nil && params.Password == nil {
+		respondWithError(w, 400, "nothing to update")
+		return
+	}
+	if params.Email != nil {
+		*params.Email = normalizeEmail(*params.Email)
+	}
+	fields := cfg.validateUserFields(params.Email, params.Password)
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, fields)
+		return
+	}
+
+	cfg.changeCredentials(w, r, userid, params.Email, params.Password, params.CurrentPassword)
+}
+
+// changeCredentials applies a validated email and/or password change once
+// currentPassword checks out. A new password signs out every session and
+// access token and answers with fresh ones for this client.
+func (cfg *ApiConfig) changeCredentials(w http.ResponseWriter, r *http.Request, userid uuid.UUID, email, password *string, currentPassword string) {
+	previous, err := cfg.DB.GetUserByID(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	// a stolen access token alone shouldn't be enough to take over the account
+	if !cfg.confirmPassword(w, r, previous, currentPassword) {
+		return
+	}
+
+	update := database.PatchUserParams{ID: userid}
+	if email != nil {
+		update.Email = sql.NullString{String: *email, Valid: true}
+	}
+	if password != nil {
+		hashedpass, err := auth.HashPassword(*password, cfg.PasswordHashParams)
+		if err != nil {
+			log.Printf("Error hashing password: %v", err)
+			respondWithError(w, 500, "Failed to hash password")
+			return
+		}
+		update.HashedPassword = sql.NullString{String: hashedpass, Valid: true}
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		respondWithError(w, 500, "Failed to update user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	user, err := qtx.PatchUser(r.Context(), update)
+	if isUniqueViolation(err) {
+		respondWithFieldErrors(w, map[string]string{"email": "email is already registered"})
 		return
 	}
-	user, err := cfg.DB.UpdateUser(context.Background(), database.UpdateUserParams{
-		ID:             userid,
-		Email:          params.Email,
-		HashedPassword: sql.NullString{String: hashedpass, Valid: true},
-	})
 	if err != nil {
 		log.Printf("Error updating user: %v", err)
 		respondWithError(w, 500, "Failed to update user")
 		return
 	}
+	// a new password signs out every other session, every personal access token
+	// and every access token, this request's included, so it gets fresh tokens of both kinds
+	accessToken := ""
+	refreshToken := ""
+	revoked := []database.RevokedJti{}
+	if password != nil {
+		err = qtx.RevokeUserRefreshTokens(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+		if err != nil {
+			log.Printf("Error revoking refresh tokens: %v", err)
+			respondWithError(w, 500, "Failed to update user")
+			return
+		}
+		revoked, err = qtx.RevokeUserAccessTokens(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+		if err != nil {
+			log.Printf("Error revoking access tokens: %v", err)
+			respondWithError(w, 500, "Failed to update user")
+			return
+		}
+		err = qtx.RevokeUserPersonalAccessTokens(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error revoking personal access tokens: %v", err)
+			respondWithError(w, 500, "Failed to update user")
+			return
+		}
+		refreshToken, err = auth.MakeRefreshToken()
+		if err != nil {
+			log.Printf("Error creating refresh token: %v", err)
+			respondWithError(w, 500, "Failed to create refresh token")
+			return
+		}
+		session := newSession(r, user.ID, refreshToken)
+		accessToken, err = auth.MakeJWT(user.ID, session.AccessJti, user.Role, cfg.Keyring, accessTokenTTL)
+		if err != nil {
+			log.Printf("Error creating token: %v", err)
+			respondWithError(w, 500, "Failed to create token")
+			return
+		}
+		_, err = qtx.CreateRefreshToken(r.Context(), session)
+		if err != nil {
+			log.Printf("refresh token creation failed: %v", err)
+			respondWithError(w, 500, "refresh token creation failed")
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing user update: %v", err)
+		respondWithError(w, 500, "Failed to update user")
+		return
+	}
+	cfg.denylist(revoked)
 
-	respondWithJSON(w, 200, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	})
+	if user.Email != previous.Email {
+		cfg.Notifier.Notify(user.ID, NotificationEmailChanged, user.ID, user.ID)
+		go cfg.sendVerificationEmail(user)
+	}
+	if password != nil {
+		cfg.Notifier.Notify(user.ID, NotificationPasswordChanged, user.ID, user.ID)
+	}
 
+	respondWithJSON(w, 200, struct {
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		IsProtected   bool      `json:"is_protected"`
+		EmailVerified bool      `json:"email_verified"`
+		Token         string    `json:"token,omitempty"`
+		RefreshToken  string    `json:"refresh_token,omitempty"`
+	}{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   cfg.chirpyRedActive(user),
+		IsProtected:   user.IsProtected,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Token:         accessToken,
+		RefreshToken:  refreshToken,
+	})
 }
 
 func (cfg *ApiConfig) Login(w http.ResponseWriter, r *http.Request) {
@@ -360,61 +560,33 @@ func (cfg *ApiConfig) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.DB.GetUser(r.Context(), params.Email)
+	throttleKeys := []string{accountThrottleKey(params.Email), ipThrottleKey(r)}
+	wait, err := cfg.loginLockout(r.Context(), throttleKeys...)
 	if err != nil {
-		log.Printf("user not found: %v", err)
-		respondWithError(w, 400, "Incorrect email or password")
+		log.Printf("Error checking login throttle: %v", err)
+		respondWithError(w, 500, "Failed to log in")
 		return
 	}
-	ok, err := auth.CheckPasswordHash(params.Password, user.HashedPassword.String)
-	if !ok {
-		log.Printf("password does not match: %v", err)
-		respondWithError(w, 401, "Incorrect email or password")
+	if wait > 0 {
+		respondLoginLocked(w, wait)
 		return
 	}
 
-	jwt_token, err := auth.MakeJWT(user.ID, cfg.SECRET_JWT, time.Hour)
+	// unknown emails and wrong passwords get the same response after the same work
+	user, err := cfg.DB.GetUser(r.Context(), normalizeEmail(params.Email))
 	if err != nil {
-		log.Printf("Error creating token: %v", err)
-		respondWithError(w, 500, "Failed to create token")
-		return
-	}
-	refreshToken, err := auth.MakeRefreshToken()
-	if err != nil {
-		log.Printf("Error creating refresh token: %v", err)
-		respondWithError(w, 500, "Failed to create refresh token")
-		return
+		log.Printf("user not found: %v", err)
+		user = database.User{}
 	}
-
-	_, err = cfg.DB.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
-		ExpiresAt: time.Now().Add(time.Hour * 1440), // 60 days
-	})
-	if err != nil {
-		log.Printf("refresh token creation failed: %v", err)
-		respondWithError(w, 500, "refresh token creation failed")
+	if !cfg.checkLoginPassword(params.Password, user.HashedPassword) {
+		log.Printf("login failed for %s", throttleKeys[0])
+		cfg.recordLoginFailure(r.Context(), throttleKeys...)
+		respondWithError(w, 401, "Incorrect email or password")
 		return
 	}
-
-	respondWithJSON(w, 200, struct {
-		ID           uuid.UUID `json:"id"`
-		CreatedAt    time.Time `json:"created_at"`
-		UpdatedAt    time.Time `json:"updated_at"`
-		Email        string    `json:"email"`
-		IsChirpyRed  bool      `json:"is_chirpy_red"`
-		Token        string    `json:"token"`
-		RefreshToken string    `json:"refresh_token"`
-	}{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		IsChirpyRed:  user.IsChirpyRed,
-		Token:        jwt_token,
-		RefreshToken: refreshToken,
-	})
-
+	cfg.clearLoginFailures(r.Context(), user.Email)
+	cfg.upgradePasswordHash(r.Context(), user, params.Password)
+	cfg.finishFirstFactor(w, r, user)
 }
 
 func (cfg *ApiConfig) Refresh(w http.ResponseWriter, r *http.Request) {
@@ -425,7 +597,7 @@ func (cfg *ApiConfig) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := cfg.DB.GetRefreshToken(context.Background(), tkn)
+	token, err := cfg.DB.GetRefreshToken(context.Background(), auth.HashToken(tkn))
 	if err != nil {
 		log.Printf("refresh token retreival failed: %v", err)
 		respondWithError(w, 401, "refresh token invalid")
@@ -444,7 +616,31 @@ func (cfg *ApiConfig) Refresh(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 500, "token generation failed")
 		return
 	}
-	jwt_token, err := auth.MakeJWT(token.UserID.UUID, cfg.SECRET_JWT, time.Hour)
+	// tokens issued to OAuth clients are refreshed at /oauth/token, keeping their scope
+	if token.ClientID.Valid {
+		respondWithError(w, 401, "refresh token invalid")
+		return
+	}
+
+	newRefreshToken, child, err := cfg.rotateSession(r, token)
+	if errors.Is(err, errRefreshTokenReused) {
+		respondWithError(w, 401, "refresh token revoked")
+		return
+	}
+	if err != nil {
+		log.Printf("Error rotating refresh token: %v", err)
+		respondWithError(w, 500, "token generation failed")
+		return
+	}
+
+	// the role is read again so changes reach the next access token
+	user, err := cfg.DB.GetUserByID(r.Context(), token.UserID.UUID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 500, "token generation failed")
+		return
+	}
+	jwt_token, err := auth.MakeJWT(user.ID, child.AccessJti, user.Role, cfg.Keyring, accessTokenTTL)
 	if err != nil {
 		log.Printf("Error creating token: %v", err)
 		respondWithError(w, 500, "Failed to create token")
@@ -452,9 +648,11 @@ func (cfg *ApiConfig) Refresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	respondWithJSON(w, 200, struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}{
-		Token: jwt_token,
+		Token:        jwt_token,
+		RefreshToken: newRefreshToken,
 	})
 
 }
@@ -466,12 +664,25 @@ func (cfg *ApiConfig) Revoke(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 401, "Token missing or invalid")
 		return
 	}
-	err = cfg.DB.RevokeRefreshToken(context.Background(), tkn)
+	token, err := cfg.DB.GetRefreshToken(r.Context(), auth.HashToken(tkn))
+	if err != nil {
+		log.Printf("refresh token retreival failed: %v", err)
+		respondWithError(w, 401, "refresh token invalid")
+		return
+	}
+	err = cfg.DB.RevokeRefreshToken(r.Context(), token.TokenHash)
 	if err != nil {
 		log.Printf("revoking token failed: %v", err)
 		respondWithError(w, 401, "refresh token invalid")
 		return
 	}
+	// logging out also ends the access tokens issued to this session
+	err = cfg.revokeFamilyAccessTokens(r.Context(), token.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+		respondWithError(w, 500, "Failed to revoke access tokens")
+		return
+	}
 	respondWithJSON(w, 204, nil)
 }
 
@@ -506,12 +717,15 @@ func (cfg *ApiConfig) PolkaWebhook(w http.ResponseWriter, r *http.Request) {
 		respondWithJSON(w, 204, nil)
 		return
 	} else {
+		// the payment is recorded whatever the email status, since Polka retries
+		// anything but 2xx and 404; chirpyRedActive gates the membership instead
 		err = cfg.DB.UpgradeUser(context.Background(), params.Data.UserID)
 		if err != nil {
 			log.Printf("upgrading user failed: %v", err)
 			respondWithError(w, 404, "upgrading user failed")
 			return
 		}
+		cfg.Notifier.Notify(params.Data.UserID, NotificationChirpyRed, params.Data.UserID, uuid.Nil)
 		respondWithJSON(w, 204, nil)
 	}
 
@@ -528,6 +742,85 @@ func (cfg *ApiConfig) MiddlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// finishFirstFactor follows a successful password or magic link check. With
+// two-factor auth on it only hands out a challenge for POST /api/login/2fa.
+func (cfg *ApiConfig) finishFirstFactor(w http.ResponseWriter, r *http.Request, user database.User) {
+	if !user.TotpEnabledAt.Valid {
+		cfg.completeLogin(w, r, user)
+		return
+	}
+	challenge, err := auth.MakeSignedToken(loginChallengePurpose, user.ID, "", cfg.SECRET_JWT, loginChallengeTTL)
+	if err != nil {
+		log.Printf("Error creating login challenge: %v", err)
+		respondWithError(w, 500, "Failed to create token")
+		return
+	}
+	respondWithJSON(w, 200, struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+	})
+}
+
+// completeLogin issues the access and refresh tokens once every login factor has been checked.
+func (cfg *ApiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	if user.DeletionRequestedAt.Valid {
+		_, err := cfg.DB.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error cancelling deletion: %v", err)
+			respondWithError(w, 500, "Failed to cancel account deletion")
+			return
+		}
+		log.Printf("account deletion cancelled by login for user %s", user.ID)
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating refresh token: %v", err)
+		respondWithError(w, 500, "Failed to create refresh token")
+		return
+	}
+	session := newSession(r, user.ID, refreshToken)
+	jwt_token, err := auth.MakeJWT(user.ID, session.AccessJti, user.Role, cfg.Keyring, accessTokenTTL)
+	if err != nil {
+		log.Printf("Error creating token: %v", err)
+		respondWithError(w, 500, "Failed to create token")
+		return
+	}
+
+	_, err = cfg.DB.CreateRefreshToken(r.Context(), session)
+	if err != nil {
+		log.Printf("refresh token creation failed: %v", err)
+		respondWithError(w, 500, "refresh token creation failed")
+		return
+	}
+
+	respondWithJSON(w, 200, struct {
+		ID            uuid.UUID `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Email         string    `json:"email"`
+		IsChirpyRed   bool      `json:"is_chirpy_red"`
+		IsProtected   bool      `json:"is_protected"`
+		EmailVerified bool      `json:"email_verified"`
+		Token         string    `json:"token"`
+		RefreshToken  string    `json:"refresh_token"`
+	}{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   cfg.chirpyRedActive(user),
+		IsProtected:   user.IsProtected,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Token:         jwt_token,
+		RefreshToken:  refreshToken,
+	})
+
+}
+
 func respondWithError(w http.ResponseWriter, code int, msg string) {
 	type returnErr struct {
 		Error string `json:"error"`
@@ -559,6 +852,53 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(dat)
 }
 
+// viewerFromRequest returns the authenticated user for endpoints where auth is optional.
+// A request without a usable token is anonymous and gets uuid.Nil, so a stale or
+// invalid Authorization header never breaks a public read.
+// Personal access tokens need the chirps:read scope to read as their owner.
+func (cfg *ApiConfig) viewerFromRequest(r *http.Request) uuid.UUID {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil
+	}
+	viewer, err := cfg.authenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		log.Printf("reading anonymously, token not accepted: %v", err)
+		return uuid.Nil
+	}
+	return viewer
+}
+
+// parsePagination reads the limit and offset query parameters, defaulting to the first 20 items.
+func parsePagination(r *http.Request) (int32, int32, error) {
+	limit, offset := int64(20), int64(0)
+	var err error
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.ParseInt(l, 10, 32)
+		if err != nil || limit < 1 || limit > 100 {
+			return 0, 0, fmt.Errorf("limit must be between 1 and 100")
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		offset, err = strconv.ParseInt(o, 10, 32)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must not be negative")
+		}
+	}
+	return int32(limit), int32(offset), nil
+}
+
+// validateBody enforces the length limit shared by chirps and direct messages
+// and returns the body with profanity masked. kind names the thing in error messages.
+func validateBody(body, kind string) (string, error) {
+	if len(body) == 0 {
+		return "", fmt.Errorf("Body is required")
+	}
+	if len(body) > 140 {
+		return "", fmt.Errorf("%s is too long", kind)
+	}
+	return cleanifyString(body), nil
+}
+
 func cleanifyString(s string) string {
 	str := strings.Split(s, " ")
 	badwords := map[string]bool{
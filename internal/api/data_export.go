@@ -0,0 +1,389 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+	"github.com/o0n1x/chirpy/internal/mail"
+)
+
+// export statuses, matching the data_exports.status column
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+const NotificationExportReady = "export_ready"
+
+const dataExportTTL = 48 * time.Hour
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// RunExportCleanupWorker removes export archives once their download link has expired.
+func (cfg *ApiConfig) RunExportCleanupWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := cfg.cleanupExpiredExports(ctx)
+		if err != nil {
+			log.Printf("Error cleaning up data exports: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *ApiConfig) cleanupExpiredExports(ctx context.Context) error {
+	exports, err := cfg.DB.GetExpiredDataExports(ctx)
+	if err != nil {
+		return err
+	}
+	// one archive that can't be removed mustn't keep the rest on disk
+	failures := []error{}
+	for _, export := range exports {
+		err = removeExportFile(export)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("removing export %s: %w", export.ID, err))
+			continue
+		}
+		err = cfg.DB.ExpireDataExport(ctx, export.ID)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("expiring export %s: %w", export.ID, err))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) CreateDataExport(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	export, err := cfg.DB.CreateDataExport(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error creating data export: %v", err)
+		respondWithError(w, 500, "Failed to create data export")
+		return
+	}
+
+	go cfg.buildDataExport(export, user)
+	respondWithJSON(w, 202, toDataExport(export))
+}
+
+func (cfg *ApiConfig) GetDataExport(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	exportUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid export ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	export, err := cfg.DB.GetDataExport(r.Context(), database.GetDataExportParams{
+		ID:     exportUUID,
+		UserID: userid,
+	})
+	if err != nil {
+		log.Printf("Error retrieving data export: %v", err)
+		respondWithError(w, 404, "export not found")
+		return
+	}
+	respondWithJSON(w, 200, toDataExport(export))
+}
+
+// DownloadDataExport is reached from the emailed link, so the token in the
+// query string is the only credential.
+func (cfg *ApiConfig) DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, 400, "token is required")
+		return
+	}
+	export, err := cfg.DB.GetDataExportByToken(r.Context(), sql.NullString{String: auth.HashToken(token), Valid: true})
+	if err != nil {
+		log.Printf("Error retrieving data export: %v", err)
+		respondWithError(w, 404, "download link is invalid or has expired")
+		return
+	}
+
+	f, err := os.Open(export.FilePath.String)
+	if err != nil {
+		log.Printf("Error opening export archive: %v", err)
+		respondWithError(w, 404, "download link is invalid or has expired")
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, export.CreatedAt.UTC().Format("2006-01-02")))
+	http.ServeContent(w, r, "", export.CompletedAt.Time, f)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// buildDataExport runs in the background; the user is emailed a download link
+// once the archive is written.
+func (cfg *ApiConfig) buildDataExport(export database.DataExport, user database.User) {
+	ctx := context.Background()
+	path, err := cfg.writeExportArchive(ctx, export, user)
+	if err != nil {
+		log.Printf("Error building data export %s: %v", export.ID, err)
+		cfg.failDataExport(ctx, export.ID, "")
+		return
+	}
+
+	downloadToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating download token: %v", err)
+		cfg.failDataExport(ctx, export.ID, path)
+		return
+	}
+	expiresAt := time.Now().Add(dataExportTTL)
+	err = cfg.DB.CompleteDataExport(ctx, database.CompleteDataExportParams{
+		ID:                export.ID,
+		FilePath:          sql.NullString{String: path, Valid: true},
+		DownloadTokenHash: sql.NullString{String: auth.HashToken(downloadToken), Valid: true},
+		ExpiresAt:         sql.NullTime{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error completing data export: %v", err)
+		cfg.failDataExport(ctx, export.ID, path)
+		return
+	}
+
+	cfg.Notifier.Notify(user.ID, NotificationExportReady, export.ID, user.ID)
+	link := fmt.Sprintf("%s/api/exports/download?token=%s", cfg.BaseURL, url.QueryEscape(downloadToken))
+	err = cfg.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy data export is ready",
+		Body: fmt.Sprintf("The archive of your Chirpy data is ready. Download it from this link before %s:\n\n%s\n",
+			expiresAt.UTC().Format(time.RFC1123), link),
+	})
+	if err != nil {
+		log.Printf("Error sending data export email: %v", err)
+	}
+}
+
+// failDataExport marks an export failed and removes its archive, if one was
+// written, since a failed export's file is never cleaned up otherwise.
+func (cfg *ApiConfig) failDataExport(ctx context.Context, exportID uuid.UUID, path string) {
+	if path != "" {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing export archive: %v", err)
+		}
+	}
+	err := cfg.DB.FailDataExport(ctx, exportID)
+	if err != nil {
+		log.Printf("Error marking data export failed: %v", err)
+	}
+}
+
+// writeExportArchive returns the path of the finished archive. On error no file is left behind.
+func (cfg *ApiConfig) writeExportArchive(ctx context.Context, export database.DataExport, user database.User) (_ string, err error) {
+	chirps, err := cfg.DB.GetChirpsByAuthor(ctx, database.GetChirpsByAuthorParams{
+		UserID:   uuid.NullUUID{UUID: user.ID, Valid: true},
+		ViewerID: user.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+	sessions, err := cfg.DB.GetActiveRefreshTokensForUser(ctx, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(cfg.ExportDir, 0o700)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(cfg.ExportDir, export.ID.String()+".zip")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	defer func() {
+		// a partial archive of personal data must not stay on disk
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+		}
+	}()
+
+	zw := zip.NewWriter(f)
+	err = writeExportJSON(zw, "profile.json", struct {
+		ID              uuid.UUID  `json:"id"`
+		CreatedAt       time.Time  `json:"created_at"`
+		UpdatedAt       time.Time  `json:"updated_at"`
+		Email           string     `json:"email"`
+		IsChirpyRed     bool       `json:"is_chirpy_red"`
+		IsProtected     bool       `json:"is_protected"`
+		EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	}{
+		ID:              user.ID,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		Email:           user.Email,
+		IsChirpyRed:     user.IsChirpyRed,
+		IsProtected:     user.IsProtected,
+		EmailVerifiedAt: nullTimePtr(user.EmailVerifiedAt),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	returningChirps := []Chirp{}
+	for _, chirp := range chirps {
+		returningChirps = append(returningChirps, Chirp{
+			ID:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			Body:      chirp.Body,
+			UserID:    chirp.UserID.UUID,
+		})
+	}
+	err = writeExportJSON(zw, "chirps.json", returningChirps)
+	if err != nil {
+		return "", err
+	}
+	err = writeExportChirpsCSV(zw, returningChirps)
+	if err != nil {
+		return "", err
+	}
+
+	// Session leaves out the token values; they would let anyone holding the archive log in
+	returningSessions := []Session{}
+	for _, session := range sessions {
+		returningSessions = append(returningSessions, toSession(session))
+	}
+	err = writeExportJSON(zw, "sessions.json", returningSessions)
+	if err != nil {
+		return "", err
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
+
+func writeExportJSON(zw *zip.Writer, name string, v any) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeExportChirpsCSV(zw *zip.Writer, chirps []Chirp) error {
+	fw, err := zw.Create("chirps.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(fw)
+	err = cw.Write([]string{"id", "created_at", "updated_at", "body"})
+	if err != nil {
+		return err
+	}
+	for _, chirp := range chirps {
+		err = cw.Write([]string{
+			chirp.ID.String(),
+			chirp.CreatedAt.UTC().Format(time.RFC3339),
+			chirp.UpdatedAt.UTC().Format(time.RFC3339),
+			chirp.Body,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func removeExportFile(export database.DataExport) error {
+	if !export.FilePath.Valid {
+		return nil
+	}
+	err := os.Remove(export.FilePath.String)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func toDataExport(e database.DataExport) DataExport {
+	return DataExport{
+		ID:          e.ID,
+		CreatedAt:   e.CreatedAt,
+		Status:      e.Status,
+		CompletedAt: nullTimePtr(e.CompletedAt),
+		ExpiresAt:   nullTimePtr(e.ExpiresAt),
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+// follow statuses, matching the follows.status column
+const (
+	FollowPending  = "pending"
+	FollowAccepted = "accepted"
+)
+
+// notification kinds for follows
+const (
+	NotificationFollow         = "follow"
+	NotificationFollowRequest  = "follow_request"
+	NotificationFollowAccepted = "follow_accepted"
+)
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Status     string    `json:"status"`
+}
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) FollowUser(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	followeeUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	if followeeUUID == userid {
+		respondWithError(w, 400, "users cannot follow themselves")
+		return
+	}
+	followee, err := cfg.DB.GetUserByID(r.Context(), followeeUUID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+
+	// following a protected account only creates a request until the owner approves it
+	status := FollowAccepted
+	if followee.IsProtected {
+		status = FollowPending
+	}
+	follow, err := cfg.DB.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: userid,
+		FolloweeID: followee.ID,
+		Status:     status,
+	})
+	if err != nil {
+		log.Printf("Error creating follow: %v", err)
+		respondWithError(w, 500, "Failed to follow user")
+		return
+	}
+
+	if follow.Status == FollowPending {
+		cfg.Notifier.Notify(followee.ID, NotificationFollowRequest, followee.ID, userid)
+		respondWithJSON(w, 202, toFollow(follow))
+		return
+	}
+	cfg.Notifier.Notify(followee.ID, NotificationFollow, followee.ID, userid)
+	respondWithJSON(w, 200, toFollow(follow))
+}
+
+func (cfg *ApiConfig) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	followeeUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	deleted, err := cfg.DB.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: userid,
+		FolloweeID: followeeUUID,
+	})
+	if err != nil {
+		log.Printf("Error deleting follow: %v", err)
+		respondWithError(w, 500, "Failed to unfollow user")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "not following this user")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+func (cfg *ApiConfig) GetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	requests, err := cfg.DB.GetFollowRequests(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving follow requests: %v", err)
+		respondWithError(w, 500, "Failed to retrieve follow requests")
+		return
+	}
+
+	returningRequests := []Follow{}
+	for _, request := range requests {
+		returningRequests = append(returningRequests, toFollow(request))
+	}
+	respondWithJSON(w, 200, returningRequests)
+}
+
+func (cfg *ApiConfig) ApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	followerUUID, err := uuid.Parse(r.PathValue("follower_id"))
+	if err != nil {
+		log.Printf("Error invalid follower ID: %v", err)
+		respondWithError(w, 400, "invalid follower ID")
+		return
+	}
+	updated, err := cfg.DB.AcceptFollowRequest(r.Context(), database.AcceptFollowRequestParams{
+		FollowerID: followerUUID,
+		FolloweeID: userid,
+	})
+	if err != nil {
+		log.Printf("Error approving follow request: %v", err)
+		respondWithError(w, 500, "Failed to approve follow request")
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, 404, "follow request not found")
+		return
+	}
+	cfg.Notifier.Notify(followerUUID, NotificationFollowAccepted, userid, userid)
+	respondWithJSON(w, 204, nil)
+}
+
+func (cfg *ApiConfig) RejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	followerUUID, err := uuid.Parse(r.PathValue("follower_id"))
+	if err != nil {
+		log.Printf("Error invalid follower ID: %v", err)
+		respondWithError(w, 400, "invalid follower ID")
+		return
+	}
+	deleted, err := cfg.DB.RejectFollowRequest(r.Context(), database.RejectFollowRequestParams{
+		FollowerID: followerUUID,
+		FolloweeID: userid,
+	})
+	if err != nil {
+		log.Printf("Error rejecting follow request: %v", err)
+		respondWithError(w, 500, "Failed to reject follow request")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "follow request not found")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+func (cfg *ApiConfig) SetProtected(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		IsProtected bool `json:"is_protected"`
+	}
+
+	userid, ok := cfg.authorize(w, r, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+
+	user, err := cfg.DB.SetUserProtected(r.Context(), database.SetUserProtectedParams{
+		ID:          userid,
+		IsProtected: params.IsProtected,
+	})
+	if err != nil {
+		log.Printf("Error updating user: %v", err)
+		respondWithError(w, 500, "Failed to update user")
+		return
+	}
+	// an account that is no longer protected has nothing left to approve
+	if !user.IsProtected {
+		err = cfg.DB.AcceptAllFollowRequests(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error accepting follow requests: %v", err)
+		}
+	}
+
+	respondWithJSON(w, 200, User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   cfg.chirpyRedActive(user),
+		IsProtected:   user.IsProtected,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	})
+}
+
+func toFollow(f database.Follow) Follow {
+	return Follow{
+		FollowerID: f.FollowerID,
+		FolloweeID: f.FolloweeID,
+		CreatedAt:  f.CreatedAt,
+		Status:     f.Status,
+	}
+}
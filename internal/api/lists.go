@@ -0,0 +1,386 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+const maxListNameLength = 64
+
+type List struct {
+	ID        uuid.UUID   `json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	OwnerID   uuid.UUID   `json:"owner_id"`
+	Name      string      `json:"name"`
+	IsPrivate bool        `json:"is_private"`
+	MemberIDs []uuid.UUID `json:"member_ids"`
+}
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) CreateList(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name      string `json:"name"`
+		IsPrivate bool   `json:"is_private"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	if len(params.Name) == 0 || len(params.Name) > maxListNameLength {
+		respondWithError(w, 400, "Name must be between 1 and 64 characters")
+		return
+	}
+
+	list, err := cfg.DB.CreateList(r.Context(), database.CreateListParams{
+		OwnerID:   userid,
+		Name:      params.Name,
+		IsPrivate: params.IsPrivate,
+	})
+	if err != nil {
+		log.Printf("Error creating list: %v", err)
+		respondWithError(w, 500, "Failed to create list")
+		return
+	}
+	respondWithJSON(w, 201, toList(list, nil))
+}
+
+func (cfg *ApiConfig) GetLists(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	lists, err := cfg.DB.GetListsByOwner(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving lists: %v", err)
+		respondWithError(w, 500, "Failed to retrieve lists")
+		return
+	}
+
+	returningLists := []List{}
+	for _, list := range lists {
+		members, err := cfg.DB.GetListMembers(r.Context(), list.ID)
+		if err != nil {
+			log.Printf("Error retrieving list members: %v", err)
+			respondWithError(w, 500, "Failed to retrieve lists")
+			return
+		}
+		returningLists = append(returningLists, toList(list, members))
+	}
+	respondWithJSON(w, 200, returningLists)
+}
+
+func (cfg *ApiConfig) GetList(w http.ResponseWriter, r *http.Request) {
+	viewer := cfg.viewerFromRequest(r)
+	list, ok := cfg.listForViewer(w, r, viewer)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.DB.GetListMembers(r.Context(), list.ID)
+	if err != nil {
+		log.Printf("Error retrieving list members: %v", err)
+		respondWithError(w, 500, "Failed to retrieve list")
+		return
+	}
+	respondWithJSON(w, 200, toList(list, members))
+}
+
+func (cfg *ApiConfig) UpdateList(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name      string `json:"name"`
+		IsPrivate bool   `json:"is_private"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	list, ok := cfg.listForOwner(w, r, userid)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	if len(params.Name) == 0 || len(params.Name) > maxListNameLength {
+		respondWithError(w, 400, "Name must be between 1 and 64 characters")
+		return
+	}
+
+	list, err = cfg.DB.UpdateList(r.Context(), database.UpdateListParams{
+		ID:        list.ID,
+		Name:      params.Name,
+		IsPrivate: params.IsPrivate,
+	})
+	if err != nil {
+		log.Printf("Error updating list: %v", err)
+		respondWithError(w, 500, "Failed to update list")
+		return
+	}
+	members, err := cfg.DB.GetListMembers(r.Context(), list.ID)
+	if err != nil {
+		log.Printf("Error retrieving list members: %v", err)
+		respondWithError(w, 500, "Failed to retrieve list")
+		return
+	}
+	respondWithJSON(w, 200, toList(list, members))
+}
+
+func (cfg *ApiConfig) DeleteList(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	list, ok := cfg.listForOwner(w, r, userid)
+	if !ok {
+		return
+	}
+
+	err = cfg.DB.DeleteList(r.Context(), list.ID)
+	if err != nil {
+		log.Printf("Error deleting list: %v", err)
+		respondWithError(w, 500, "Failed to delete list")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+func (cfg *ApiConfig) AddListMember(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	list, ok := cfg.listForOwner(w, r, userid)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	_, err = cfg.DB.GetUserByID(r.Context(), params.UserID)
+	if err != nil {
+		log.Printf("Error retrieving list member: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+
+	err = cfg.DB.AddListMember(r.Context(), database.AddListMemberParams{
+		ListID: list.ID,
+		UserID: params.UserID,
+	})
+	if err != nil {
+		log.Printf("Error adding list member: %v", err)
+		respondWithError(w, 500, "Failed to add list member")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+func (cfg *ApiConfig) RemoveListMember(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	list, ok := cfg.listForOwner(w, r, userid)
+	if !ok {
+		return
+	}
+
+	memberUUID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		respondWithError(w, 400, "invalid user ID")
+		return
+	}
+	removed, err := cfg.DB.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: memberUUID,
+	})
+	if err != nil {
+		log.Printf("Error removing list member: %v", err)
+		respondWithError(w, 500, "Failed to remove list member")
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, 404, "user is not a member of this list")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+func (cfg *ApiConfig) GetListChirps(w http.ResponseWriter, r *http.Request) {
+	viewer := cfg.viewerFromRequest(r)
+	list, ok := cfg.listForViewer(w, r, viewer)
+	if !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	chirps, err := cfg.DB.GetListChirps(r.Context(), database.GetListChirpsParams{
+		ListID:     list.ID,
+		ViewerID:   viewer,
+		PageLimit:  limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving list chirps: %v", err)
+		respondWithError(w, 500, "Failed to retrieve chirps")
+		return
+	}
+
+	returningChirps := []Chirp{}
+	for _, chirp := range chirps {
+		returningChirps = append(returningChirps, Chirp{
+			ID:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			Body:      chirp.Body,
+			UserID:    chirp.UserID.UUID,
+		})
+	}
+	respondWithJSON(w, 200, returningChirps)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// listForViewer loads the {id} list if viewer may see it. Private lists are only
+// visible to their owner; everyone else gets the same 404 as a missing list.
+func (cfg *ApiConfig) listForViewer(w http.ResponseWriter, r *http.Request, viewer uuid.UUID) (database.List, bool) {
+	listUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid list ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return database.List{}, false
+	}
+	list, err := cfg.DB.GetList(r.Context(), listUUID)
+	if err != nil {
+		log.Printf("Error retrieving list: %v", err)
+		respondWithError(w, 404, "list not found")
+		return database.List{}, false
+	}
+	if list.IsPrivate && list.OwnerID != viewer {
+		respondWithError(w, 404, "list not found")
+		return database.List{}, false
+	}
+	return list, true
+}
+
+// listForOwner loads the {id} list and checks that userID owns it.
+func (cfg *ApiConfig) listForOwner(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.List, bool) {
+	list, ok := cfg.listForViewer(w, r, userID)
+	if !ok {
+		return database.List{}, false
+	}
+	if list.OwnerID != userID {
+		respondWithError(w, 403, "this user is not the owner of the list")
+		return database.List{}, false
+	}
+	return list, true
+}
+
+func toList(l database.List, members []database.ListMember) List {
+	list := List{
+		ID:        l.ID,
+		CreatedAt: l.CreatedAt,
+		UpdatedAt: l.UpdatedAt,
+		OwnerID:   l.OwnerID,
+		Name:      l.Name,
+		IsPrivate: l.IsPrivate,
+		MemberIDs: []uuid.UUID{},
+	}
+	for _, member := range members {
+		list.MemberIDs = append(list.MemberIDs, member.UserID)
+	}
+	return list
+}
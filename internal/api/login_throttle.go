@@ -0,0 +1,266 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+// Failed logins are counted per account and per IP. Once a counter reaches its
+// threshold the key is locked, for a minute at first and twice as long after
+// each further failure, up to maxLoginLockout. Counters reset after 24 quiet hours.
+// Magic link and password reset requests use the same counters under their own
+// keys, counting every request rather than failures, since each one sends an email.
+const (
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+	magicLinkEmailThreshold = 3
+	magicLinkIPThreshold    = 10
+	resetEmailThreshold     = 3
+	resetIPThreshold        = 10
+	baseLoginLockout        = time.Minute
+	maxLoginLockout         = time.Hour
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// RunLoginThrottleCleanupWorker drops counters that have gone quiet.
+func (cfg *ApiConfig) RunLoginThrottleCleanupWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := cfg.DB.DeleteStaleLoginThrottles(ctx)
+		if err != nil {
+			log.Printf("Error cleaning up login throttles: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//
+// HANDLERS
+//
+
+// UnlockAccount is mounted behind RequireRole.
+func (cfg *ApiConfig) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid user ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	user, err := cfg.DB.GetUserByID(r.Context(), userUUID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	_, err = cfg.DB.DeleteLoginThrottle(r.Context(), accountThrottleKey(user.Email))
+	if err != nil {
+		log.Printf("Error unlocking account: %v", err)
+		respondWithError(w, 500, "Failed to unlock account")
+		return
+	}
+	log.Printf("account %s unlocked by %s", user.ID, actorFromContext(r.Context()))
+	respondWithJSON(w, 204, nil)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+func accountThrottleKey(email string) string {
+	return "account:" + normalizeEmail(email)
+}
+
+func ipThrottleKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+func magicLinkEmailThrottleKey(email string) string {
+	return "magic:account:" + normalizeEmail(email)
+}
+
+func magicLinkIPThrottleKey(r *http.Request) string {
+	return "magic:ip:" + clientIP(r)
+}
+
+func resetEmailThrottleKey(email string) string {
+	return "reset:account:" + normalizeEmail(email)
+}
+
+func resetIPThrottleKey(r *http.Request) string {
+	return "reset:ip:" + clientIP(r)
+}
+
+// throttleThreshold is how many counts a key takes before it locks.
+func throttleThreshold(key string) int {
+	switch {
+	case strings.HasPrefix(key, "magic:account:"):
+		return magicLinkEmailThreshold
+	case strings.HasPrefix(key, "magic:ip:"):
+		return magicLinkIPThreshold
+	case strings.HasPrefix(key, "reset:account:"):
+		return resetEmailThreshold
+	case strings.HasPrefix(key, "reset:ip:"):
+		return resetIPThreshold
+	case strings.HasPrefix(key, "ip:"):
+		return ipFailureThreshold
+	}
+	return accountFailureThreshold
+}
+
+// loginLockout returns how long until every key is unlocked, or 0 if none is locked.
+func (cfg *ApiConfig) loginLockout(ctx context.Context, keys ...string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range keys {
+		throttle, err := cfg.DB.GetLoginThrottle(ctx, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if throttle.LockedUntil.Valid {
+			wait = max(wait, time.Until(throttle.LockedUntil.Time))
+		}
+	}
+	return wait, nil
+}
+
+func (cfg *ApiConfig) recordLoginFailure(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		throttle, err := cfg.DB.RecordLoginFailure(ctx, key)
+		if err != nil {
+			log.Printf("Error recording login failure: %v", err)
+			continue
+		}
+		threshold := throttleThreshold(key)
+		if int(throttle.Failures) < threshold {
+			continue
+		}
+		lockout := maxLoginLockout
+		if shift := int(throttle.Failures) - threshold; shift < 6 {
+			lockout = min(baseLoginLockout<<shift, maxLoginLockout)
+		}
+		err = cfg.DB.LockLoginThrottle(ctx, database.LockLoginThrottleParams{
+			Key:         key,
+			LockedUntil: sql.NullTime{Time: time.Now().Add(lockout), Valid: true},
+		})
+		if err != nil {
+			log.Printf("Error locking login: %v", err)
+			continue
+		}
+		log.Printf("login locked for %s after %d failures", key, throttle.Failures)
+	}
+}
+
+// clearLoginFailures resets an account's counter after a successful login.
+// The IP counter is left alone so one valid account can't be used to reset it.
+func (cfg *ApiConfig) clearLoginFailures(ctx context.Context, email string) {
+	_, err := cfg.DB.DeleteLoginThrottle(ctx, accountThrottleKey(email))
+	if err != nil {
+		log.Printf("Error clearing login failures: %v", err)
+	}
+}
+
+// respondLoginLocked answers a throttled login with 429 and Retry-After.
+func respondLoginLocked(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprint(int(wait.Seconds())+1))
+	respondWithError(w, 429, "Too many failed login attempts, try again later")
+}
+
+// checkLoginPassword compares against a throwaway hash when the account doesn't
+// exist or has no password, so every failure costs the same Argon2 work.
+func (cfg *ApiConfig) checkLoginPassword(password string, hash sql.NullString) bool {
+	if !hash.Valid {
+		dummyHashOnce.Do(func() {
+			var err error
+			dummyHash, err = auth.HashPassword("chirpy-dummy-password", cfg.PasswordHashParams)
+			if err != nil {
+				log.Printf("Error creating dummy hash: %v", err)
+			}
+		})
+		auth.CheckPasswordHash(password, dummyHash)
+		return false
+	}
+	ok, err := auth.CheckPasswordHash(password, hash.String)
+	if err != nil {
+		log.Printf("Error checking password: %v", err)
+	}
+	return ok
+}
+
+// confirmPassword re-authenticates a logged-in user before a sensitive change
+// and answers the request itself when that fails. Guesses share the login
+// failure counters, so a stolen access token can't be used to brute-force the
+// password. Accounts created by an OIDC sign-in have no password to confirm;
+// they are sent to the emailed reset flow to set one, which proves they still
+// hold the address.
+func (cfg *ApiConfig) confirmPassword(w http.ResponseWriter, r *http.Request, user database.User, password string) bool {
+	if !user.HashedPassword.Valid {
+		respondWithError(w, 403, "This account has no password yet; set one with POST /api/password/forgot first")
+		return false
+	}
+	throttleKeys := []string{accountThrottleKey(user.Email), ipThrottleKey(r)}
+	wait, err := cfg.loginLockout(r.Context(), throttleKeys...)
+	if err != nil {
+		log.Printf("Error checking login throttle: %v", err)
+		respondWithError(w, 500, "Failed to check password")
+		return false
+	}
+	if wait > 0 {
+		respondLoginLocked(w, wait)
+		return false
+	}
+	ok, err := auth.CheckPasswordHash(password, user.HashedPassword.String)
+	if !ok {
+		log.Printf("password does not match: %v", err)
+		cfg.recordLoginFailure(r.Context(), throttleKeys...)
+		respondWithError(w, 401, "Incorrect password")
+		return false
+	}
+	cfg.clearLoginFailures(r.Context(), user.Email)
+	return true
+}
+
+// upgradePasswordHash rehashes a password that was just checked against an
+// outdated hash with the current parameters. It only replaces the exact hash it
+// was given, so a password changed in the meantime is left alone.
+func (cfg *ApiConfig) upgradePasswordHash(ctx context.Context, user database.User, password string) {
+	if !user.HashedPassword.Valid || !auth.NeedsRehash(user.HashedPassword.String, cfg.PasswordHashParams) {
+		return
+	}
+	hashedpass, err := auth.HashPassword(password, cfg.PasswordHashParams)
+	if err != nil {
+		log.Printf("Error rehashing password: %v", err)
+		return
+	}
+	err = cfg.DB.RehashUserPassword(ctx, database.RehashUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: user.HashedPassword,
+		NewHash:        sql.NullString{String: hashedpass, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error saving rehashed password: %v", err)
+		return
+	}
+	log.Printf("upgraded password hash for user %s to %s", user.ID, cfg.PasswordHashParams)
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+	"github.com/o0n1x/chirpy/internal/mail"
+)
+
+const (
+	magicLinkTTL = 10 * time.Minute
+	// the link only works in the browser that asked for it, which holds this cookie
+	magicLinkDeviceCookie = "chirpy_magic_device"
+	magicLinkCookiePath   = "/api/login/magic"
+)
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+
+	// a locked account can't get around the lockout by switching to links, and
+	// every request counts, so nobody can flood an inbox with login emails
+	emailKey, ipKey := magicLinkEmailThrottleKey(params.Email), magicLinkIPThrottleKey(r)
+	wait, err := cfg.loginLockout(r.Context(), accountThrottleKey(params.Email), ipThrottleKey(r), emailKey, ipKey)
+	if err != nil {
+		log.Printf("Error checking login throttle: %v", err)
+		respondWithError(w, 500, "Failed to send login link")
+		return
+	}
+	if wait > 0 {
+		respondLoginLocked(w, wait)
+		return
+	}
+	cfg.recordLoginFailure(r.Context(), emailKey, ipKey)
+
+	// the cookie is set whether or not the account exists, so the response gives nothing away
+	deviceSecret, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating device secret: %v", err)
+		respondWithError(w, 500, "Failed to send login link")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkDeviceCookie,
+		Value:    deviceSecret,
+		Path:     magicLinkCookiePath,
+		MaxAge:   int(magicLinkTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	user, err := cfg.DB.GetUser(r.Context(), normalizeEmail(params.Email))
+	if err != nil {
+		log.Printf("login link requested for unknown email: %v", err)
+		respondWithJSON(w, 202, nil)
+		return
+	}
+	linkToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating login link: %v", err)
+		respondWithError(w, 500, "Failed to send login link")
+		return
+	}
+	err = cfg.DB.CreateMagicLink(r.Context(), database.CreateMagicLinkParams{
+		TokenHash:  auth.HashToken(linkToken),
+		UserID:     user.ID,
+		DeviceHash: auth.HashToken(deviceSecret),
+		ExpiresAt:  time.Now().Add(magicLinkTTL),
+	})
+	if err != nil {
+		log.Printf("Error saving login link: %v", err)
+		respondWithError(w, 500, "Failed to send login link")
+		return
+	}
+
+	go cfg.sendMagicLinkEmail(user.Email, linkToken)
+	respondWithJSON(w, 202, nil)
+}
+
+// RedeemMagicLink returns the same payload as Login, or a 2FA challenge.
+func (cfg *ApiConfig) RedeemMagicLink(w http.ResponseWriter, r *http.Request) {
+	linkToken := r.URL.Query().Get("token")
+	if linkToken == "" {
+		respondWithError(w, 400, "token is required")
+		return
+	}
+
+	wait, err := cfg.loginLockout(r.Context(), ipThrottleKey(r))
+	if err != nil {
+		log.Printf("Error checking login throttle: %v", err)
+		respondWithError(w, 500, "Failed to log in")
+		return
+	}
+	if wait > 0 {
+		respondLoginLocked(w, wait)
+		return
+	}
+
+	device, err := r.Cookie(magicLinkDeviceCookie)
+	if err != nil {
+		log.Printf("login link opened without device cookie: %v", err)
+		respondWithError(w, 401, "login link is invalid, expired or was requested from another device")
+		return
+	}
+	userid, err := cfg.DB.UseMagicLink(r.Context(), database.UseMagicLinkParams{
+		TokenHash:  auth.HashToken(linkToken),
+		DeviceHash: auth.HashToken(device.Value),
+	})
+	if err != nil {
+		log.Printf("Error using login link: %v", err)
+		cfg.recordLoginFailure(r.Context(), ipThrottleKey(r))
+		respondWithError(w, 401, "login link is invalid, expired or was requested from another device")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:   magicLinkDeviceCookie,
+		Path:   magicLinkCookiePath,
+		MaxAge: -1,
+	})
+	// earlier links stay valid until one is used, so requesting a link for
+	// someone else can't invalidate theirs; using one ends the rest
+	err = cfg.DB.DeleteMagicLinks(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error deleting other login links: %v", err)
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 401, "login link is invalid, expired or was requested from another device")
+		return
+	}
+	cfg.clearLoginFailures(r.Context(), user.Email)
+	_, err = cfg.DB.DeleteLoginThrottle(r.Context(), magicLinkEmailThrottleKey(user.Email))
+	if err != nil {
+		log.Printf("Error clearing login link requests: %v", err)
+	}
+	cfg.finishFirstFactor(w, r, user)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+func (cfg *ApiConfig) sendMagicLinkEmail(email, linkToken string) {
+	link := fmt.Sprintf("%s/api/login/magic/verify?token=%s", cfg.BaseURL, url.QueryEscape(linkToken))
+	err := cfg.Mailer.Send(context.Background(), mail.Message{
+		To:      email,
+		Subject: "Your Chirpy login link",
+		Body: fmt.Sprintf("Open this link in the same browser you asked for it from to log in to Chirpy. It works once, within 10 minutes:\n\n%s\n\n"+
+			"If this wasn't you, you can ignore this email.\n", link),
+	})
+	if err != nil {
+		log.Printf("Error sending login link email: %v", err)
+	}
+}
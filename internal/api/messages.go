@@ -0,0 +1,439 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+// NotificationMessage is sent to the other members when a direct message arrives.
+const NotificationMessage = "message"
+
+// maxConversationMembers caps group conversations, including the creator.
+const maxConversationMembers = 10
+
+type Conversation struct {
+	ID        uuid.UUID            `json:"id"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Members   []ConversationMember `json:"members"`
+}
+
+type ConversationMember struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	SenderID       *uuid.UUID  `json:"sender_id"`
+	Body           string      `json:"body"`
+	ReadBy         []uuid.UUID `json:"read_by"`
+}
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+
+	members := []uuid.UUID{userid}
+	seen := map[uuid.UUID]bool{userid: true}
+	for _, memberID := range params.MemberIDs {
+		if seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+		_, err := cfg.DB.GetUserByID(r.Context(), memberID)
+		if err != nil {
+			log.Printf("Error retrieving conversation member: %v", err)
+			respondWithError(w, 400, "unknown member "+memberID.String())
+			return
+		}
+		members = append(members, memberID)
+	}
+	if len(members) < 2 {
+		respondWithError(w, 400, "a conversation needs at least one other member")
+		return
+	}
+	if len(members) > maxConversationMembers {
+		respondWithError(w, 400, "too many members")
+		return
+	}
+
+	// one-to-one conversations are reused instead of duplicated
+	if len(members) == 2 {
+		existing, err := cfg.DB.FindDirectConversation(r.Context(), database.FindDirectConversationParams{
+			UserA: members[0],
+			UserB: members[1],
+		})
+		if err == nil {
+			conversation, err := cfg.loadConversation(r.Context(), existing)
+			if err != nil {
+				log.Printf("Error retrieving conversation: %v", err)
+				respondWithError(w, 500, "Failed to retrieve conversation")
+				return
+			}
+			respondWithJSON(w, 200, conversation)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error finding conversation: %v", err)
+			respondWithError(w, 500, "Failed to create conversation")
+			return
+		}
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		respondWithError(w, 500, "Failed to create conversation")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	created, err := qtx.CreateConversation(r.Context(), uuid.NullUUID{UUID: userid, Valid: true})
+	if err != nil {
+		log.Printf("Error creating conversation: %v", err)
+		respondWithError(w, 500, "Failed to create conversation")
+		return
+	}
+	for _, memberID := range members {
+		err = qtx.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+			ConversationID: created.ID,
+			UserID:         memberID,
+		})
+		if err != nil {
+			log.Printf("Error adding conversation member: %v", err)
+			respondWithError(w, 500, "Failed to create conversation")
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing conversation: %v", err)
+		respondWithError(w, 500, "Failed to create conversation")
+		return
+	}
+
+	conversation, err := cfg.loadConversation(r.Context(), created)
+	if err != nil {
+		log.Printf("Error retrieving conversation: %v", err)
+		respondWithError(w, 500, "Failed to retrieve conversation")
+		return
+	}
+	respondWithJSON(w, 201, conversation)
+}
+
+func (cfg *ApiConfig) GetConversations(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	conversations, err := cfg.DB.GetConversationsForUser(r.Context(), database.GetConversationsForUserParams{
+		UserID: userid,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving conversations: %v", err)
+		respondWithError(w, 500, "Failed to retrieve conversations")
+		return
+	}
+
+	returningConversations := []Conversation{}
+	for _, c := range conversations {
+		conversation, err := cfg.loadConversation(r.Context(), c)
+		if err != nil {
+			log.Printf("Error retrieving conversation: %v", err)
+			respondWithError(w, 500, "Failed to retrieve conversations")
+			return
+		}
+		returningConversations = append(returningConversations, conversation)
+	}
+	respondWithJSON(w, 200, returningConversations)
+}
+
+func (cfg *ApiConfig) GetMessages(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	conversationUUID, ok := cfg.conversationForMember(w, r, userid)
+	if !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	messages, err := cfg.DB.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: conversationUUID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving messages: %v", err)
+		respondWithError(w, 500, "Failed to retrieve messages")
+		return
+	}
+	members, err := cfg.DB.GetConversationMembers(r.Context(), conversationUUID)
+	if err != nil {
+		log.Printf("Error retrieving conversation members: %v", err)
+		respondWithError(w, 500, "Failed to retrieve messages")
+		return
+	}
+
+	returningMessages := []Message{}
+	for _, message := range messages {
+		returningMessages = append(returningMessages, toMessage(message, members))
+	}
+	respondWithJSON(w, 200, returningMessages)
+}
+
+func (cfg *ApiConfig) CreateMessage(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	conversationUUID, ok := cfg.conversationForMember(w, r, userid)
+	if !ok {
+		return
+	}
+	if !cfg.requireVerifiedEmail(w, r, userid, ActionMessages) {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	params.Body, err = validateBody(params.Body, "Message")
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	message, err := cfg.DB.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: conversationUUID,
+		SenderID:       uuid.NullUUID{UUID: userid, Valid: true},
+		Body:           params.Body,
+	})
+	if err != nil {
+		log.Printf("Error creating message: %v", err)
+		respondWithError(w, 500, "Failed to create message")
+		return
+	}
+	// sending a message implies the sender has read the conversation
+	err = cfg.DB.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationUUID,
+		UserID:         userid,
+	})
+	if err != nil {
+		log.Printf("Error marking conversation read: %v", err)
+	}
+	err = cfg.DB.TouchConversation(r.Context(), conversationUUID)
+	if err != nil {
+		log.Printf("Error updating conversation: %v", err)
+	}
+
+	members, err := cfg.DB.GetConversationMembers(r.Context(), conversationUUID)
+	if err != nil {
+		log.Printf("Error retrieving conversation members: %v", err)
+		respondWithError(w, 500, "Failed to retrieve conversation")
+		return
+	}
+	for _, member := range members {
+		if member.UserID != userid {
+			cfg.Notifier.Notify(member.UserID, NotificationMessage, conversationUUID, userid)
+		}
+	}
+
+	respondWithJSON(w, 201, toMessage(message, members))
+}
+
+func (cfg *ApiConfig) MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	conversationUUID, ok := cfg.conversationForMember(w, r, userid)
+	if !ok {
+		return
+	}
+	err = cfg.DB.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationUUID,
+		UserID:         userid,
+	})
+	if err != nil {
+		log.Printf("Error marking conversation read: %v", err)
+		respondWithError(w, 500, "Failed to update conversation")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// conversationForMember parses the {id} path value and checks that userID belongs to it.
+// Non-members get the same 404 as a missing conversation.
+func (cfg *ApiConfig) conversationForMember(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (uuid.UUID, bool) {
+	conversationUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid conversation ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return uuid.UUID{}, false
+	}
+	isMember, err := cfg.DB.IsConversationMember(r.Context(), database.IsConversationMemberParams{
+		ConversationID: conversationUUID,
+		UserID:         userID,
+	})
+	if err != nil {
+		log.Printf("Error checking conversation membership: %v", err)
+		respondWithError(w, 500, "Failed to retrieve conversation")
+		return uuid.UUID{}, false
+	}
+	if !isMember {
+		respondWithError(w, 404, "conversation not found")
+		return uuid.UUID{}, false
+	}
+	return conversationUUID, true
+}
+
+func (cfg *ApiConfig) loadConversation(ctx context.Context, c database.Conversation) (Conversation, error) {
+	members, err := cfg.DB.GetConversationMembers(ctx, c.ID)
+	if err != nil {
+		return Conversation{}, err
+	}
+	conversation := Conversation{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Members:   []ConversationMember{},
+	}
+	for _, member := range members {
+		m := ConversationMember{
+			UserID:   member.UserID,
+			JoinedAt: member.JoinedAt,
+		}
+		if member.LastReadAt.Valid {
+			m.LastReadAt = &member.LastReadAt.Time
+		}
+		conversation.Members = append(conversation.Members, m)
+	}
+	return conversation, nil
+}
+
+// toMessage fills in read receipts: every other member whose last read is at or after the message.
+func toMessage(m database.Message, members []database.ConversationMember) Message {
+	message := Message{
+		ID:             m.ID,
+		CreatedAt:      m.CreatedAt,
+		ConversationID: m.ConversationID,
+		Body:           m.Body,
+		ReadBy:         []uuid.UUID{},
+	}
+	if m.SenderID.Valid {
+		message.SenderID = &m.SenderID.UUID
+	}
+	for _, member := range members {
+		if m.SenderID.Valid && member.UserID == m.SenderID.UUID {
+			continue
+		}
+		if member.LastReadAt.Valid && !member.LastReadAt.Time.Before(m.CreatedAt) {
+			message.ReadBy = append(message.ReadBy, member.UserID)
+		}
+	}
+	return message
+}
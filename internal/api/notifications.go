@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+// notification kinds
+const (
+	NotificationChirpyRed       = "chirpy_red"
+	NotificationEmailChanged    = "email_changed"
+	NotificationPasswordChanged = "password_changed"
+)
+
+type Notification struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Kind       string     `json:"kind"`
+	SubjectID  uuid.UUID  `json:"subject_id"`
+	ActorID    *uuid.UUID `json:"actor_id"`
+	ActorCount int32      `json:"actor_count"`
+	ReadAt     *time.Time `json:"read_at"`
+}
+
+// Notifier writes notifications in the background so handlers never wait on it.
+// Unread notifications with the same user, kind and subject are grouped into one row,
+// which counts each distinct actor once.
+type Notifier struct {
+	conn   *sql.DB
+	db     *database.Queries
+	events chan database.UpsertNotificationParams
+}
+
+func NewNotifier(conn *sql.DB, buffer int) *Notifier {
+	return &Notifier{
+		conn:   conn,
+		db:     database.New(conn),
+		events: make(chan database.UpsertNotificationParams, buffer),
+	}
+}
+
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-n.events:
+			err := n.save(ctx, event)
+			if err != nil {
+				log.Printf("Error saving notification: %v", err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) save(ctx context.Context, event database.UpsertNotificationParams) error {
+	tx, err := n.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := n.db.WithTx(tx)
+
+	notificationID, err := qtx.UpsertNotification(ctx, event)
+	if err != nil {
+		return err
+	}
+	if event.ActorID.Valid {
+		err = qtx.AddNotificationActor(ctx, database.AddNotificationActorParams{
+			NotificationID: notificationID,
+			ActorID:        event.ActorID.UUID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Notify queues a notification for userID. actorID may be uuid.Nil for system events.
+// If the queue is full the notification is dropped rather than blocking the caller.
+func (n *Notifier) Notify(userID uuid.UUID, kind string, subjectID uuid.UUID, actorID uuid.UUID) {
+	event := database.UpsertNotificationParams{
+		UserID:    userID,
+		Kind:      kind,
+		SubjectID: subjectID,
+		ActorID:   uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+	}
+	select {
+	case n.events <- event:
+	default:
+		log.Printf("notification queue full, dropping %s notification for user %s", kind, userID)
+	}
+}
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	notifications, err := cfg.DB.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID: userid,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving notifications: %v", err)
+		respondWithError(w, 500, "Failed to retrieve notifications")
+		return
+	}
+	unread, err := cfg.DB.CountUnreadNotifications(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error counting notifications: %v", err)
+		respondWithError(w, 500, "Failed to retrieve notifications")
+		return
+	}
+
+	returningNotifications := []Notification{}
+	for _, notification := range notifications {
+		returningNotifications = append(returningNotifications, toNotification(notification))
+	}
+
+	respondWithJSON(w, 200, struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+		Limit         int32          `json:"limit"`
+		Offset        int32          `json:"offset"`
+	}{
+		Notifications: returningNotifications,
+		UnreadCount:   unread,
+		Limit:         limit,
+		Offset:        offset,
+	})
+}
+
+func (cfg *ApiConfig) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	notificationUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid notification ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	updated, err := cfg.DB.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationUUID,
+		UserID: userid,
+	})
+	if err != nil {
+		log.Printf("Error marking notification read: %v", err)
+		respondWithError(w, 500, "Failed to update notification")
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, 404, "notification not found")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+func (cfg *ApiConfig) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	err = cfg.DB.MarkAllNotificationsRead(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error marking notifications read: %v", err)
+		respondWithError(w, 500, "Failed to update notifications")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+func toNotification(n database.Notification) Notification {
+	notification := Notification{
+		ID:         n.ID,
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+		Kind:       n.Kind,
+		SubjectID:  n.SubjectID,
+		ActorCount: n.ActorCount,
+	}
+	if n.ActorID.Valid {
+		notification.ActorID = &n.ActorID.UUID
+	}
+	if n.ReadAt.Valid {
+		notification.ReadAt = &n.ReadAt.Time
+	}
+	return notification
+}
@@ -0,0 +1,594 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+const authorizationCodeTTL = 5 * time.Minute
+
+// what the consent screen tells the user each scope allows
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:   "Read chirps, including ones from protected accounts you follow",
+	auth.ScopeChirpsWrite:  "Post and delete chirps as you",
+	auth.ScopeProfileWrite: "Make your account protected or public",
+}
+
+// the consent screen doubles as the login form, since the API has no browser session
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+  <head><title>Authorize {{.ClientName}}</title></head>
+  <body>
+    <h1>Authorize {{.ClientName}}</h1>
+    <p>{{.ClientName}} is asking to:</p>
+    <ul>
+      {{range .Scopes}}<li>{{.}}</li>
+      {{end}}
+    </ul>
+    {{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+    <form method="post" action="/oauth/authorize">
+      <input type="hidden" name="response_type" value="code">
+      <input type="hidden" name="client_id" value="{{.ClientID}}">
+      <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+      <input type="hidden" name="scope" value="{{.Scope}}">
+      <input type="hidden" name="state" value="{{.State}}">
+      <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+      <input type="hidden" name="code_challenge_method" value="S256">
+      <p><label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username"></label></p>
+      <p><label>Password <input type="password" name="password" autocomplete="current-password"></label></p>
+      <p><label>Two-factor code, if enabled <input name="code" autocomplete="one-time-code"></label></p>
+      <button name="decision" value="allow">Allow</button>
+      <button name="decision" value="deny">Deny</button>
+    </form>
+    <p>You'll be sent back to {{.RedirectHost}}.</p>
+  </body>
+</html>`))
+
+var oauthErrorTemplate = template.Must(template.New("oauth_error").Parse(`<!DOCTYPE html>
+<html>
+  <head><title>Authorization failed</title></head>
+  <body>
+    <h1>Authorization failed</h1>
+    <p>{{.}}</p>
+  </body>
+</html>`))
+
+// authorizeRequest is a validated authorization request.
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scope         string
+	State         string
+	CodeChallenge string
+}
+
+// oauthError is an RFC 6749 error code with a description for developers.
+type oauthError struct {
+	Code        string
+	Description string
+}
+
+func (e oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// RunOAuthCleanupWorker deletes expired authorization codes every interval.
+func (cfg *ApiConfig) RunOAuthCleanupWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := cfg.DB.DeleteExpiredAuthorizationCodes(ctx)
+		if err != nil {
+			log.Printf("Error cleaning up authorization codes: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//
+// HANDLERS
+//
+
+// OAuthMetadata publishes the RFC 8414 authorization server metadata.
+func (cfg *ApiConfig) OAuthMetadata(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	respondWithJSON(w, 200, struct {
+		Issuer                            string   `json:"issuer"`
+		AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+		TokenEndpoint                     string   `json:"token_endpoint"`
+		RevocationEndpoint                string   `json:"revocation_endpoint"`
+		JWKSURI                           string   `json:"jwks_uri"`
+		ScopesSupported                   []string `json:"scopes_supported"`
+		ResponseTypesSupported            []string `json:"response_types_supported"`
+		GrantTypesSupported               []string `json:"grant_types_supported"`
+		CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+		TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	}{
+		Issuer:                            cfg.BaseURL,
+		AuthorizationEndpoint:             cfg.BaseURL + "/oauth/authorize",
+		TokenEndpoint:                     cfg.BaseURL + "/oauth/token",
+		RevocationEndpoint:                cfg.BaseURL + "/oauth/revoke",
+		JWKSURI:                           cfg.BaseURL + "/.well-known/jwks.json",
+		ScopesSupported:                   auth.SupportedScopes(),
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// Authorize shows the consent screen for an authorization request.
+func (cfg *ApiConfig) Authorize(w http.ResponseWriter, r *http.Request) {
+	req, err := cfg.parseAuthorizeRequest(r.Context(), r.URL.Query())
+	if err != nil {
+		cfg.respondAuthorizeError(w, r, req, err)
+		return
+	}
+	renderConsent(w, 200, req, "", "")
+}
+
+// Approve handles the consent form. The user signs in on the form itself, so
+// approving needs the password, and the 2FA code when it is on.
+func (cfg *ApiConfig) Approve(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondOAuthErrorPage(w, 400, "The request could not be read.")
+		return
+	}
+	req, err := cfg.parseAuthorizeRequest(r.Context(), r.PostForm)
+	if err != nil {
+		cfg.respondAuthorizeError(w, r, req, err)
+		return
+	}
+	if r.PostForm.Get("decision") != "allow" {
+		redirectWithParams(w, r, req.RedirectURI, url.Values{"error": {"access_denied"}, "state": {req.State}})
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	throttleKeys := []string{accountThrottleKey(email), ipThrottleKey(r)}
+	wait, err := cfg.loginLockout(r.Context(), throttleKeys...)
+	if err != nil {
+		log.Printf("Error checking login throttle: %v", err)
+		respondOAuthErrorPage(w, 500, "Something went wrong, please try again.")
+		return
+	}
+	if wait > 0 {
+		renderConsent(w, 429, req, email, "Too many failed attempts. Try again in "+wait.Round(time.Second).String()+".")
+		return
+	}
+
+	user, err := cfg.DB.GetUser(r.Context(), normalizeEmail(email))
+	if err != nil {
+		user = database.User{}
+	}
+	if !cfg.checkLoginPassword(r.PostForm.Get("password"), user.HashedPassword) {
+		log.Printf("OAuth login failed for %s", throttleKeys[0])
+		cfg.recordLoginFailure(r.Context(), throttleKeys...)
+		renderConsent(w, 401, req, email, "Incorrect email or password.")
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		ok, err := cfg.checkSecondFactor(r, user, r.PostForm.Get("code"))
+		if err != nil {
+			log.Printf("Error checking second factor: %v", err)
+			respondOAuthErrorPage(w, 500, "Something went wrong, please try again.")
+			return
+		}
+		if !ok {
+			cfg.recordLoginFailure(r.Context(), throttleKeys...)
+			renderConsent(w, 401, req, email, "Incorrect two-factor code.")
+			return
+		}
+	}
+	cfg.clearLoginFailures(r.Context(), user.Email)
+	cfg.upgradePasswordHash(r.Context(), user, r.PostForm.Get("password"))
+	// approving an app isn't a login that cancels a pending deletion, and the
+	// grant would be purged with the account, so the user has to cancel it first
+	if user.DeletionRequestedAt.Valid {
+		renderConsent(w, 403, req, email, "This account is scheduled for deletion. Log in to Chirpy to cancel the deletion, then try again.")
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating authorization code: %v", err)
+		respondOAuthErrorPage(w, 500, "Something went wrong, please try again.")
+		return
+	}
+	err = cfg.DB.CreateAuthorizationCode(r.Context(), database.CreateAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.RedirectURI,
+		Scope:         req.Scope,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		log.Printf("Error storing authorization code: %v", err)
+		respondOAuthErrorPage(w, 500, "Something went wrong, please try again.")
+		return
+	}
+	log.Printf("user %s authorized client %s for %q", user.ID, req.Client.ID, req.Scope)
+	redirectWithParams(w, r, req.RedirectURI, url.Values{"code": {code}, "state": {req.State}})
+}
+
+// OAuthToken is the token endpoint for the authorization_code and refresh_token grants.
+func (cfg *ApiConfig) OAuthToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, 400, oauthError{"invalid_request", "the body must be form-encoded"})
+		return
+	}
+	client, err := cfg.authenticateClient(r)
+	if err != nil {
+		log.Printf("OAuth client authentication failed: %v", err)
+		respondWithOAuthError(w, 401, oauthError{"invalid_client", "client authentication failed"})
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		cfg.refreshOAuthToken(w, r, client)
+	default:
+		respondWithOAuthError(w, 400, oauthError{"unsupported_grant_type", "grant_type must be authorization_code or refresh_token"})
+	}
+}
+
+// RevokeOAuthToken implements RFC 7009. Refresh tokens end their whole session;
+// unknown tokens and tokens of other clients are ignored, as the RFC asks.
+func (cfg *ApiConfig) RevokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, 400, oauthError{"invalid_request", "the body must be form-encoded"})
+		return
+	}
+	client, err := cfg.authenticateClient(r)
+	if err != nil {
+		log.Printf("OAuth client authentication failed: %v", err)
+		respondWithOAuthError(w, 401, oauthError{"invalid_client", "client authentication failed"})
+		return
+	}
+	tkn := r.PostForm.Get("token")
+
+	refreshToken, err := cfg.DB.GetRefreshToken(r.Context(), auth.HashToken(tkn))
+	if err == nil && refreshToken.ClientID.Valid && refreshToken.ClientID.UUID == client.ID {
+		err = cfg.DB.RevokeRefreshTokenFamily(r.Context(), refreshToken.FamilyID)
+		if err != nil {
+			log.Printf("Error revoking refresh token family: %v", err)
+			respondWithOAuthError(w, 503, oauthError{"temporarily_unavailable", "try again later"})
+			return
+		}
+		err = cfg.revokeFamilyAccessTokens(r.Context(), refreshToken.FamilyID)
+		if err != nil {
+			log.Printf("Error revoking access tokens: %v", err)
+		}
+		w.WriteHeader(200)
+		return
+	}
+
+	claims, err := auth.ParseAccessToken(tkn, cfg.Keyring)
+	if err == nil && claims.ClientID == client.ID.String() {
+		jti, err := uuid.Parse(claims.ID)
+		if err == nil {
+			err = cfg.DB.RevokeJTI(r.Context(), database.RevokeJTIParams{
+				Jti:       jti,
+				ExpiresAt: claims.ExpiresAt.Time,
+			})
+		}
+		if err != nil {
+			log.Printf("Error revoking access token: %v", err)
+			respondWithOAuthError(w, 503, oauthError{"temporarily_unavailable", "try again later"})
+			return
+		}
+		cfg.Keyring.Denylist.Add(claims.ID, claims.ExpiresAt.Time)
+	}
+	w.WriteHeader(200)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// parseAuthorizeRequest validates an authorization request. Until client_id and
+// redirect_uri check out the request is returned without a redirect URI, and
+// errors must be shown to the user instead of sent to the client.
+func (cfg *ApiConfig) parseAuthorizeRequest(ctx context.Context, values url.Values) (authorizeRequest, error) {
+	req := authorizeRequest{}
+	clientID, err := uuid.Parse(values.Get("client_id"))
+	if err != nil {
+		return req, errors.New("unknown client")
+	}
+	req.Client, err = cfg.DB.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		return req, errors.New("unknown client")
+	}
+	redirectURI := values.Get("redirect_uri")
+	if !slices.Contains(strings.Fields(req.Client.RedirectUris), redirectURI) {
+		return req, errors.New("the redirect URI is not registered for this client")
+	}
+	req.RedirectURI = redirectURI
+	req.State = values.Get("state")
+
+	if values.Get("response_type") != "code" {
+		return req, oauthError{"unsupported_response_type", "response_type must be code"}
+	}
+	// PKCE is required of every client, confidential ones included
+	req.CodeChallenge = values.Get("code_challenge")
+	if values.Get("code_challenge_method") != "S256" || !auth.ValidPKCEChallenge(req.CodeChallenge) {
+		return req, oauthError{"invalid_request", "an S256 code_challenge is required"}
+	}
+	requested := strings.Fields(values.Get("scope"))
+	if len(requested) == 0 {
+		requested = strings.Fields(req.Client.Scopes)
+	}
+	for _, scope := range requested {
+		if !auth.HasScope(req.Client.Scopes, scope) {
+			return req, oauthError{"invalid_scope", "the client is not registered for " + scope}
+		}
+	}
+	req.Scope, err = auth.ParseScopes(requested)
+	if err != nil {
+		return req, oauthError{"invalid_scope", err.Error()}
+	}
+	return req, nil
+}
+
+// respondAuthorizeError sends protocol errors back to the client's redirect URI
+// and shows the rest, which can't be trusted with a redirect, to the user.
+func (cfg *ApiConfig) respondAuthorizeError(w http.ResponseWriter, r *http.Request, req authorizeRequest, err error) {
+	var oauthErr oauthError
+	if errors.As(err, &oauthErr) {
+		redirectWithParams(w, r, req.RedirectURI, url.Values{
+			"error":             {oauthErr.Code},
+			"error_description": {oauthErr.Description},
+			"state":             {req.State},
+		})
+		return
+	}
+	respondOAuthErrorPage(w, 400, err.Error()+".")
+}
+
+// authenticateClient reads the client credentials from HTTP Basic auth or the
+// form. Public clients only send client_id.
+func (cfg *ApiConfig) authenticateClient(r *http.Request) (database.OauthClient, error) {
+	rawID, secret, ok := r.BasicAuth()
+	if ok {
+		// RFC 6749 form-encodes the credentials before they go into the header
+		rawID, _ = url.QueryUnescape(rawID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		rawID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+	clientID, err := uuid.Parse(rawID)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	client, err := cfg.DB.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if client.SecretHash.Valid && subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) != 1 {
+		return database.OauthClient{}, errors.New("wrong client secret")
+	}
+	return client, nil
+}
+
+func (cfg *ApiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	// the code is spent here even if a check below fails, so it can't be retried.
+	// It records the session it is exchanged for, so that a reuse can end it.
+	codeHash := auth.HashToken(r.PostForm.Get("code"))
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating refresh token: %v", err)
+		respondWithOAuthError(w, 500, oauthError{"server_error", "failed to create tokens"})
+		return
+	}
+	session := newSession(r, uuid.Nil, refreshToken)
+	code, err := cfg.DB.UseAuthorizationCode(r.Context(), database.UseAuthorizationCodeParams{
+		CodeHash: codeHash,
+		FamilyID: uuid.NullUUID{UUID: session.FamilyID, Valid: true},
+	})
+	if err != nil {
+		cfg.revokeReusedCodeFamily(r, codeHash)
+		respondWithOAuthError(w, 400, oauthError{"invalid_grant", "the code is invalid, expired or already used"})
+		return
+	}
+	if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+		respondWithOAuthError(w, 400, oauthError{"invalid_grant", "the code was issued to another client or redirect URI"})
+		return
+	}
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, 400, oauthError{"invalid_grant", "code_verifier does not match the code challenge"})
+		return
+	}
+
+	session.UserID = uuid.NullUUID{UUID: code.UserID, Valid: true}
+	session.ClientID = uuid.NullUUID{UUID: client.ID, Valid: true}
+	session.Scope = sql.NullString{String: code.Scope, Valid: true}
+	_, err = cfg.DB.CreateRefreshToken(r.Context(), session)
+	if err != nil {
+		log.Printf("refresh token creation failed: %v", err)
+		respondWithOAuthError(w, 500, oauthError{"server_error", "failed to create tokens"})
+		return
+	}
+	cfg.respondWithOAuthTokens(w, client, session, refreshToken)
+}
+
+// revokeReusedCodeFamily ends the tokens issued for an authorization code that
+// is presented again, as RFC 6749 section 4.1.2 asks, since one of the two
+// holders of the code is not the client it was meant for.
+func (cfg *ApiConfig) revokeReusedCodeFamily(r *http.Request, codeHash string) {
+	code, err := cfg.DB.GetUsedAuthorizationCode(r.Context(), codeHash)
+	if err != nil || !code.FamilyID.Valid {
+		return
+	}
+	log.Printf("authorization code reused for user %s, revoking session %s", code.UserID, code.FamilyID.UUID)
+	err = cfg.DB.RevokeRefreshTokenFamily(r.Context(), code.FamilyID.UUID)
+	if err != nil {
+		log.Printf("Error revoking refresh token family: %v", err)
+	}
+	err = cfg.revokeFamilyAccessTokens(r.Context(), code.FamilyID.UUID)
+	if err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+	}
+}
+
+func (cfg *ApiConfig) refreshOAuthToken(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	token, err := cfg.DB.GetRefreshToken(r.Context(), auth.HashToken(r.PostForm.Get("refresh_token")))
+	if err != nil || !token.ClientID.Valid || token.ClientID.UUID != client.ID || !token.UserID.Valid {
+		respondWithOAuthError(w, 400, oauthError{"invalid_grant", "the refresh token is invalid"})
+		return
+	}
+	if token.RevokedAt.Valid || token.ExpiresAt.Before(time.Now()) {
+		respondWithOAuthError(w, 400, oauthError{"invalid_grant", "the refresh token is expired or revoked"})
+		return
+	}
+
+	refreshToken, child, err := cfg.rotateSession(r, token)
+	if errors.Is(err, errRefreshTokenReused) {
+		respondWithOAuthError(w, 400, oauthError{"invalid_grant", "the refresh token is expired or revoked"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error rotating refresh token: %v", err)
+		respondWithOAuthError(w, 500, oauthError{"server_error", "failed to create tokens"})
+		return
+	}
+	cfg.respondWithOAuthTokens(w, client, child, refreshToken)
+}
+
+// respondWithOAuthTokens signs the access token recorded on session and sends
+// it with the refresh token in the RFC 6749 response format.
+func (cfg *ApiConfig) respondWithOAuthTokens(w http.ResponseWriter, client database.OauthClient, session database.CreateRefreshTokenParams, refreshToken string) {
+	accessToken, err := auth.MakeScopedJWT(session.UserID.UUID, session.AccessJti, client.ID.String(), session.Scope.String, cfg.Keyring, accessTokenTTL)
+	if err != nil {
+		log.Printf("Error creating token: %v", err)
+		respondWithOAuthError(w, 500, oauthError{"server_error", "failed to create tokens"})
+		return
+	}
+	respondWithJSON(w, 200, struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        session.Scope.String,
+	})
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, err oauthError) {
+	if code == 401 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	respondWithJSON(w, code, struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{
+		Error:            err.Code,
+		ErrorDescription: err.Description,
+	})
+}
+
+func renderConsent(w http.ResponseWriter, code int, req authorizeRequest, email, errMsg string) {
+	scopes := []string{}
+	for _, scope := range strings.Fields(req.Scope) {
+		scopes = append(scopes, scopeDescriptions[scope])
+	}
+	redirectHost := req.RedirectURI
+	if u, err := url.Parse(req.RedirectURI); err == nil {
+		redirectHost = u.Host
+	}
+	setConsentHeaders(w)
+	w.WriteHeader(code)
+	err := consentTemplate.Execute(w, struct {
+		ClientName    string
+		ClientID      uuid.UUID
+		Scopes        []string
+		Scope         string
+		RedirectURI   string
+		RedirectHost  string
+		State         string
+		CodeChallenge string
+		Email         string
+		Error         string
+	}{
+		ClientName:    req.Client.Name,
+		ClientID:      req.Client.ID,
+		Scopes:        scopes,
+		Scope:         req.Scope,
+		RedirectURI:   req.RedirectURI,
+		RedirectHost:  redirectHost,
+		State:         req.State,
+		CodeChallenge: req.CodeChallenge,
+		Email:         email,
+		Error:         errMsg,
+	})
+	if err != nil {
+		log.Printf("Error rendering consent screen: %v", err)
+	}
+}
+
+func respondOAuthErrorPage(w http.ResponseWriter, code int, msg string) {
+	setConsentHeaders(w)
+	w.WriteHeader(code)
+	err := oauthErrorTemplate.Execute(w, msg)
+	if err != nil {
+		log.Printf("Error rendering error page: %v", err)
+	}
+}
+
+// setConsentHeaders keeps the consent screen out of caches and frames, so
+// another site can't trick a user into clicking Allow.
+func setConsentHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+}
+
+// redirectWithParams adds params to the query of a registered redirect URI.
+// An empty state is left out.
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		respondOAuthErrorPage(w, 400, "The redirect URI is invalid.")
+		return
+	}
+	query := u.Query()
+	for key, values := range params {
+		if len(values) == 1 && values[0] == "" {
+			continue
+		}
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+}
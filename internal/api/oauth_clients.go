@@ -0,0 +1,228 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+// OAuthClient is a registered third-party app as its owner sees it.
+// ClientSecret is only set in the response that registers a confidential client.
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+}
+
+//
+// HANDLERS
+//
+
+// CreateOAuthClient registers an app. Confidential clients get a secret for the
+// token endpoint; public ones, such as mobile and single-page apps, rely on PKCE.
+func (cfg *ApiConfig) CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	fields := map[string]string{}
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || len(params.Name) > 100 {
+		fields["name"] = "name must be between 1 and 100 characters"
+	}
+	if len(params.RedirectURIs) == 0 {
+		fields["redirect_uris"] = "at least one redirect URI is required"
+	}
+	for _, redirectURI := range params.RedirectURIs {
+		err = validateRedirectURI(redirectURI)
+		if err != nil {
+			fields["redirect_uris"] = err.Error()
+			break
+		}
+	}
+	scopes, err := auth.ParseScopes(params.Scopes)
+	if err != nil {
+		fields["scopes"] = err.Error()
+	}
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, fields)
+		return
+	}
+
+	secret := ""
+	secretHash := sql.NullString{}
+	if params.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			log.Printf("Error creating client secret: %v", err)
+			respondWithError(w, 500, "Failed to register client")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+	client, err := cfg.DB.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		OwnerID:      userid,
+		Name:         params.Name,
+		RedirectUris: strings.Join(params.RedirectURIs, " "),
+		Scopes:       scopes,
+		SecretHash:   secretHash,
+	})
+	if err != nil {
+		log.Printf("Error registering client: %v", err)
+		respondWithError(w, 500, "Failed to register client")
+		return
+	}
+
+	returningClient := toOAuthClient(client)
+	returningClient.ClientSecret = secret
+	respondWithJSON(w, 201, returningClient)
+}
+
+func (cfg *ApiConfig) GetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	clients, err := cfg.DB.GetOAuthClientsForOwner(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving clients: %v", err)
+		respondWithError(w, 500, "Failed to retrieve clients")
+		return
+	}
+
+	returningClients := []OAuthClient{}
+	for _, client := range clients {
+		returningClients = append(returningClients, toOAuthClient(client))
+	}
+	respondWithJSON(w, 200, returningClients)
+}
+
+// DeleteOAuthClient removes an app along with every grant users gave it.
+func (cfg *ApiConfig) DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	clientUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid client ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	client, err := cfg.DB.GetOAuthClient(r.Context(), clientUUID)
+	if err != nil || client.OwnerID != userid {
+		respondWithError(w, 404, "client not found")
+		return
+	}
+	// the refresh tokens go with the client, but issued access tokens need denylisting
+	revoked, err := cfg.DB.RevokeClientAccessTokens(r.Context(), uuid.NullUUID{UUID: client.ID, Valid: true})
+	if err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+		respondWithError(w, 500, "Failed to delete client")
+		return
+	}
+	cfg.denylist(revoked)
+	_, err = cfg.DB.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:      client.ID,
+		OwnerID: userid,
+	})
+	if err != nil {
+		log.Printf("Error deleting client: %v", err)
+		respondWithError(w, 500, "Failed to delete client")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// validateRedirectURI allows absolute https URIs, and http on a loopback
+// address for native apps. Fragments aren't allowed by RFC 6749.
+func validateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || strings.ContainsAny(raw, " \t\r\n") {
+		return fmt.Errorf("%q is not an absolute URI", raw)
+	}
+	if u.Fragment != "" {
+		return fmt.Errorf("%q must not have a fragment", raw)
+	}
+	if u.Scheme == "https" {
+		return nil
+	}
+	if u.Scheme == "http" {
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q must use https, or http on a loopback address", raw)
+}
+
+func toOAuthClient(c database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           c.ID,
+		CreatedAt:    c.CreatedAt,
+		Name:         c.Name,
+		RedirectURIs: strings.Fields(c.RedirectUris),
+		Scopes:       strings.Fields(c.Scopes),
+		Confidential: c.SecretHash.Valid,
+	}
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+	"github.com/o0n1x/chirpy/internal/oidc"
+)
+
+const (
+	oidcLoginPurpose = "oidc_login"
+	oidcLoginTTL     = 10 * time.Minute
+	// holds the state, nonce and PKCE verifier of a login until the provider redirects back
+	oidcLoginCookie     = "chirpy_oidc_login"
+	oidcLoginCookiePath = "/api/login/oidc"
+)
+
+var (
+	errOIDCNoEmail = errors.New("identity provider did not share an email address")
+	// linking on an address either side hasn't verified would let whoever
+	// registered it first take over the other account
+	errOIDCEmailNotVerified = errors.New("email address is not verified")
+)
+
+//
+// HANDLERS
+//
+
+// StartOIDCLogin redirects the browser to the identity provider.
+func (cfg *ApiConfig) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	values := make([]string, 3)
+	for i := range values {
+		value, err := auth.MakeRefreshToken()
+		if err != nil {
+			log.Printf("Error creating login state: %v", err)
+			respondWithError(w, 500, "Failed to start login")
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := cfg.OIDC.AuthCodeURL(r.Context(), state, nonce, auth.PKCEChallenge(verifier))
+	if err != nil {
+		log.Printf("Error building authorization URL: %v", err)
+		respondWithError(w, 502, "Identity provider is unavailable")
+		return
+	}
+	cookie, err := auth.MakeSignedToken(oidcLoginPurpose, uuid.Nil, strings.Join(values, "."), cfg.SECRET_JWT, oidcLoginTTL)
+	if err != nil {
+		log.Printf("Error signing login state: %v", err)
+		respondWithError(w, 500, "Failed to start login")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    cookie,
+		Path:     oidcLoginCookiePath,
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.BaseURL, "https://"),
+		// Lax, since the provider's redirect back is a cross-site navigation
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback returns the same payload as Login, or a 2FA challenge. The first
+// login through the provider links it to the account with the same verified
+// email, or creates a new account without a password.
+func (cfg *ApiConfig) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("identity provider refused login: %s %s", providerErr, query.Get("error_description"))
+		respondWithError(w, 401, "Login was cancelled or refused by the identity provider")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcLoginCookie)
+	if err != nil {
+		log.Printf("login callback without state cookie: %v", err)
+		respondWithError(w, 401, "Login expired or was started in another browser")
+		return
+	}
+	_, data, err := auth.ValidateSignedToken(cookie.Value, oidcLoginPurpose, cfg.SECRET_JWT)
+	values := strings.Split(data, ".")
+	if err != nil || len(values) != 3 {
+		log.Printf("Error validating login state: %v", err)
+		respondWithError(w, 401, "Login expired or was started in another browser")
+		return
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		respondWithError(w, 401, "Login expired or was started in another browser")
+		return
+	}
+	// each login state is good for one callback
+	http.SetCookie(w, &http.Cookie{
+		Name:   oidcLoginCookie,
+		Path:   oidcLoginCookiePath,
+		MaxAge: -1,
+	})
+
+	rawIDToken, err := cfg.OIDC.Exchange(r.Context(), query.Get("code"), verifier)
+	if err != nil {
+		log.Printf("Error exchanging authorization code: %v", err)
+		respondWithError(w, 401, "Identity provider did not accept the login")
+		return
+	}
+	claims, err := cfg.OIDC.VerifyIDToken(r.Context(), rawIDToken, nonce)
+	if err != nil {
+		log.Printf("Error verifying ID token: %v", err)
+		respondWithError(w, 401, "Identity provider did not accept the login")
+		return
+	}
+
+	user, err := cfg.userForIdentity(r.Context(), claims)
+	if errors.Is(err, errOIDCNoEmail) {
+		respondWithError(w, 400, "The identity provider did not share your email address")
+		return
+	}
+	if errors.Is(err, errOIDCEmailNotVerified) {
+		respondWithError(w, 409, "An account with this email already exists; verify the email on both sides, or log in with your password")
+		return
+	}
+	if err != nil {
+		log.Printf("Error signing in with identity provider: %v", err)
+		respondWithError(w, 500, "Failed to log in")
+		return
+	}
+	cfg.finishFirstFactor(w, r, user)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// userForIdentity returns the user the provider's subject is linked to,
+// linking or creating one on its first login.
+func (cfg *ApiConfig) userForIdentity(ctx context.Context, claims oidc.Claims) (database.User, error) {
+	email := normalizeEmail(claims.Email)
+	identity, err := cfg.DB.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Issuer:  cfg.OIDC.Issuer,
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		err = cfg.DB.TouchUserIdentity(ctx, database.TouchUserIdentityParams{
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+			Email:   email,
+		})
+		if err != nil {
+			log.Printf("Error updating identity: %v", err)
+		}
+		return cfg.DB.GetUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+	if email == "" {
+		return database.User{}, errOIDCNoEmail
+	}
+
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	created := false
+	user, err := qtx.GetUser(ctx, email)
+	if err == nil {
+		if !claims.EmailVerified() || !user.EmailVerifiedAt.Valid {
+			return database.User{}, errOIDCEmailNotVerified
+		}
+	} else if errors.Is(err, sql.ErrNoRows) {
+		user, err = qtx.CreateUser(ctx, database.CreateUserParams{Email: email})
+		if err != nil {
+			return database.User{}, err
+		}
+		created = true
+		if claims.EmailVerified() {
+			_, err = qtx.VerifyUserEmail(ctx, database.VerifyUserEmailParams{ID: user.ID, Email: email})
+			if err != nil {
+				return database.User{}, err
+			}
+			user, err = qtx.GetUserByID(ctx, user.ID)
+			if err != nil {
+				return database.User{}, err
+			}
+		}
+	} else {
+		return database.User{}, err
+	}
+
+	_, err = qtx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		Issuer:  cfg.OIDC.Issuer,
+		Subject: claims.Subject,
+		UserID:  user.ID,
+		Email:   email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return database.User{}, err
+	}
+	if created {
+		log.Printf("created user %s from identity provider login", user.ID)
+		if !user.EmailVerifiedAt.Valid {
+			go cfg.sendVerificationEmail(user)
+		}
+	} else {
+		log.Printf("linked identity provider login to user %s", user.ID)
+	}
+	return user, nil
+}
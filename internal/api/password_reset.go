@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+	"github.com/o0n1x/chirpy/internal/mail"
+)
+
+const passwordResetTTL = 30 * time.Minute
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+
+	// every request counts, so nobody can flood an inbox with reset emails. The
+	// login counters are left out: a locked account still needs a way back in.
+	emailKey, ipKey := resetEmailThrottleKey(params.Email), resetIPThrottleKey(r)
+	wait, err := cfg.loginLockout(r.Context(), emailKey, ipKey)
+	if err != nil {
+		log.Printf("Error checking reset throttle: %v", err)
+		respondWithError(w, 500, "Failed to create reset token")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(int(wait.Seconds())+1))
+		respondWithError(w, 429, "Too many password reset requests, try again later")
+		return
+	}
+	cfg.recordLoginFailure(r.Context(), emailKey, ipKey)
+
+	// the response is the same whether or not the account exists,
+	// so this endpoint can't be used to discover registered emails
+	user, err := cfg.DB.GetUser(r.Context(), normalizeEmail(params.Email))
+	if err != nil {
+		log.Printf("password reset requested for unknown email: %v", err)
+		respondWithJSON(w, 202, nil)
+		return
+	}
+
+	resetToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating reset token: %v", err)
+		respondWithError(w, 500, "Failed to create reset token")
+		return
+	}
+	err = cfg.DB.CreatePasswordResetToken(r.Context(), database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(resetToken),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		log.Printf("Error saving reset token: %v", err)
+		respondWithError(w, 500, "Failed to create reset token")
+		return
+	}
+
+	go cfg.sendPasswordResetEmail(user.Email, resetToken)
+	respondWithJSON(w, 202, nil)
+}
+
+func (cfg *ApiConfig) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	fields := cfg.validateUserFields(nil, &params.Password)
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, fields)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		respondWithError(w, 500, "Failed to reset password")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	// marks the token used in the same statement that checks it, so it only works once
+	userid, err := qtx.UsePasswordResetToken(r.Context(), auth.HashToken(params.Token))
+	if err != nil {
+		log.Printf("Error using reset token: %v", err)
+		respondWithError(w, 400, "invalid or expired reset token")
+		return
+	}
+	hashedpass, err := auth.HashPassword(params.Password, cfg.PasswordHashParams)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		respondWithError(w, 500, "Failed to hash password")
+		return
+	}
+	err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             userid,
+		HashedPassword: sql.NullString{String: hashedpass, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error updating password: %v", err)
+		respondWithError(w, 500, "Failed to update password")
+		return
+	}
+	// every existing session ends with the old password
+	err = qtx.RevokeUserRefreshTokens(r.Context(), uuid.NullUUID{UUID: userid, Valid: true})
+	if err != nil {
+		log.Printf("Error revoking refresh tokens: %v", err)
+		respondWithError(w, 500, "Failed to reset password")
+		return
+	}
+	revoked, err := qtx.RevokeUserAccessTokens(r.Context(), uuid.NullUUID{UUID: userid, Valid: true})
+	if err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+		respondWithError(w, 500, "Failed to reset password")
+		return
+	}
+	err = qtx.RevokeUserPersonalAccessTokens(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error revoking personal access tokens: %v", err)
+		respondWithError(w, 500, "Failed to reset password")
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing password reset: %v", err)
+		respondWithError(w, 500, "Failed to reset password")
+		return
+	}
+	cfg.denylist(revoked)
+
+	err = cfg.DB.DeletePasswordResetTokens(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error deleting reset tokens: %v", err)
+	}
+
+	respondWithJSON(w, 204, nil)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+func (cfg *ApiConfig) sendPasswordResetEmail(email, resetToken string) {
+	err := cfg.Mailer.Send(context.Background(), mail.Message{
+		To:      email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"Send this token with your new password to %s/api/password/reset within 30 minutes:\n\n%s\n\n"+
+			"If this wasn't you, you can ignore this email.\n", cfg.BaseURL, resetToken),
+	})
+	if err != nil {
+		log.Printf("Error sending password reset email: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+// PersonalAccessToken describes a token to its owner. Token is only set in
+// the response that creates it.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Label      string     `json:"label"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+var errInsufficientScope = errors.New("token lacks the required scope")
+
+//
+// HANDLERS
+//
+
+// CreatePersonalAccessToken needs a login session; a personal access token
+// can't mint more of itself.
+func (cfg *ApiConfig) CreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Label     string     `json:"label"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+	fields := map[string]string{}
+	params.Label = strings.TrimSpace(params.Label)
+	if params.Label == "" || len(params.Label) > 100 {
+		fields["label"] = "label must be between 1 and 100 characters"
+	}
+	scopes, err := auth.ParseScopes(params.Scopes)
+	if err != nil {
+		fields["scopes"] = err.Error()
+	}
+	expiresAt := sql.NullTime{}
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(time.Now()) {
+			fields["expires_at"] = "expires_at must be in the future"
+		}
+		expiresAt = sql.NullTime{Time: *params.ExpiresAt, Valid: true}
+	}
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, fields)
+		return
+	}
+
+	patToken, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		log.Printf("Error creating personal access token: %v", err)
+		respondWithError(w, 500, "Failed to create token")
+		return
+	}
+	pat, err := cfg.DB.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userid,
+		Label:     params.Label,
+		TokenHash: auth.HashToken(patToken),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("Error storing personal access token: %v", err)
+		respondWithError(w, 500, "Failed to create token")
+		return
+	}
+
+	returningToken := toPersonalAccessToken(pat)
+	returningToken.Token = patToken
+	respondWithJSON(w, 201, returningToken)
+}
+
+func (cfg *ApiConfig) GetPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	pats, err := cfg.DB.GetPersonalAccessTokens(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving personal access tokens: %v", err)
+		respondWithError(w, 500, "Failed to retrieve tokens")
+		return
+	}
+
+	returningTokens := []PersonalAccessToken{}
+	for _, pat := range pats {
+		returningTokens = append(returningTokens, toPersonalAccessToken(pat))
+	}
+	respondWithJSON(w, 200, returningTokens)
+}
+
+func (cfg *ApiConfig) RevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	tokenUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid token ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	revoked, err := cfg.DB.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenUUID,
+		UserID: userid,
+	})
+	if err != nil {
+		log.Printf("Error revoking personal access token: %v", err)
+		respondWithError(w, 500, "Failed to revoke token")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, 404, "token not found")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// authenticate returns the user behind the request's bearer token. Access
+// tokens from a login can do anything; personal access tokens and access
+// tokens issued to OAuth clients need scope.
+func (cfg *ApiConfig) authenticate(r *http.Request, scope string) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !strings.HasPrefix(token, auth.PersonalAccessTokenPrefix) {
+		claims, err := auth.ParseAccessToken(token, cfg.Keyring)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if claims.ClientID != "" && !auth.HasScope(claims.Scope, scope) {
+			return uuid.Nil, fmt.Errorf("%w: %s", errInsufficientScope, scope)
+		}
+		return uuid.Parse(claims.Subject)
+	}
+	pat, err := cfg.DB.UsePersonalAccessToken(r.Context(), auth.HashToken(token))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("personal access token invalid: %w", err)
+	}
+	if !auth.HasScope(pat.Scopes, scope) {
+		return uuid.Nil, fmt.Errorf("%w: %s", errInsufficientScope, scope)
+	}
+	return pat.UserID, nil
+}
+
+// authorize is authenticate for handlers: it answers 401 or 403 itself and
+// reports whether the handler should go on.
+func (cfg *ApiConfig) authorize(w http.ResponseWriter, r *http.Request, scope string) (uuid.UUID, bool) {
+	userid, err := cfg.authenticate(r, scope)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return uuid.Nil, false
+	}
+	return userid, true
+}
+
+// respondWithAuthError answers a failed authenticate: 403 when the token is
+// valid but lacks the scope, 401 otherwise.
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInsufficientScope) {
+		respondWithError(w, 403, err.Error())
+		return
+	}
+	log.Printf("Error validating token: %v", err)
+	respondWithError(w, 401, "Token missing or invalid")
+}
+
+func toPersonalAccessToken(t database.PersonalAccessToken) PersonalAccessToken {
+	return PersonalAccessToken{
+		ID:         t.ID,
+		Label:      t.Label,
+		Scopes:     strings.Fields(t.Scopes),
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  nullTimePtr(t.ExpiresAt),
+		LastUsedAt: nullTimePtr(t.LastUsedAt),
+	}
+}
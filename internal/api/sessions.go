@@ -0,0 +1,262 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+const refreshTokenTTL = time.Hour * 1440 // 60 days
+
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// Session is a refresh token family as its owner sees it; the token itself is never shown.
+// Its ID stays the same as the token is rotated.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	// set for sessions granted to a third-party app through OAuth
+	ClientID *uuid.UUID `json:"client_id,omitempty"`
+	Scope    string     `json:"scope,omitempty"`
+}
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) GetSessions(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	sessions, err := cfg.DB.GetActiveRefreshTokensForUser(r.Context(), uuid.NullUUID{UUID: userid, Valid: true})
+	if err != nil {
+		log.Printf("Error retrieving sessions: %v", err)
+		respondWithError(w, 500, "Failed to retrieve sessions")
+		return
+	}
+
+	returningSessions := []Session{}
+	for _, session := range sessions {
+		returningSessions = append(returningSessions, toSession(session))
+	}
+	respondWithJSON(w, 200, returningSessions)
+}
+
+func (cfg *ApiConfig) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	sessionUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Error invalid session ID: %v", err)
+		respondWithError(w, 400, "invalid ID")
+		return
+	}
+	revoked, err := cfg.DB.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionUUID,
+		UserID:   uuid.NullUUID{UUID: userid, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error revoking session: %v", err)
+		respondWithError(w, 500, "Failed to revoke session")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, 404, "session not found")
+		return
+	}
+	err = cfg.revokeFamilyAccessTokens(r.Context(), sessionUUID)
+	if err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+		respondWithError(w, 500, "Failed to revoke session")
+		return
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+// RevokeOtherSessions is authenticated with the refresh token of the session
+// to keep, like /api/revoke, since an access token doesn't say which session it came from.
+func (cfg *ApiConfig) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	tkn, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	current, err := cfg.DB.GetRefreshToken(r.Context(), auth.HashToken(tkn))
+	if err != nil {
+		log.Printf("refresh token retreival failed: %v", err)
+		respondWithError(w, 401, "refresh token invalid")
+		return
+	}
+	if current.RevokedAt.Valid || current.RotatedAt.Valid || current.ExpiresAt.Before(time.Now()) || !current.UserID.Valid {
+		respondWithError(w, 401, "refresh token invalid")
+		return
+	}
+
+	sessions, err := cfg.DB.GetActiveRefreshTokensForUser(r.Context(), current.UserID)
+	if err != nil {
+		log.Printf("Error retrieving sessions: %v", err)
+		respondWithError(w, 500, "Failed to revoke sessions")
+		return
+	}
+	for _, session := range sessions {
+		if session.TokenHash == current.TokenHash {
+			continue
+		}
+		err = cfg.DB.RevokeRefreshToken(r.Context(), session.TokenHash)
+		if err != nil {
+			log.Printf("revoking token failed: %v", err)
+			respondWithError(w, 500, "Failed to revoke sessions")
+			return
+		}
+		err = cfg.revokeFamilyAccessTokens(r.Context(), session.FamilyID)
+		if err != nil {
+			log.Printf("Error revoking access tokens: %v", err)
+			respondWithError(w, 500, "Failed to revoke sessions")
+			return
+		}
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// newSession builds the refresh token row for a login from r, starting a new family.
+// Only the digest of refreshToken is stored. The access token issued with it
+// must use AccessJti, so revoking the session can revoke that token too.
+func newSession(r *http.Request, userID uuid.UUID, refreshToken string) database.CreateRefreshTokenParams {
+	return database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		UserAgent: r.UserAgent(),
+		Ip:        clientIP(r),
+		FamilyID:  uuid.New(),
+		AccessJti: uuid.New(),
+		// the access token is signed just after this, so it expires a moment later
+		AccessExpiresAt: time.Now().Add(accessTokenTTL),
+	}
+}
+
+// rotateSession replaces token with a child in the same family and returns the
+// child's refresh token and row. A token that was already rotated only comes back
+// if someone kept a copy of it, so the family is revoked and errRefreshTokenReused returned.
+func (cfg *ApiConfig) rotateSession(r *http.Request, token database.RefreshToken) (string, database.CreateRefreshTokenParams, error) {
+	if token.RotatedAt.Valid {
+		cfg.revokeStolenFamily(r, token)
+		return "", database.CreateRefreshTokenParams{}, errRefreshTokenReused
+	}
+
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", database.CreateRefreshTokenParams{}, err
+	}
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return "", database.CreateRefreshTokenParams{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	_, err = qtx.RotateRefreshToken(r.Context(), token.TokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		// rotated by another request since it was read
+		cfg.revokeStolenFamily(r, token)
+		return "", database.CreateRefreshTokenParams{}, errRefreshTokenReused
+	}
+	if err != nil {
+		return "", database.CreateRefreshTokenParams{}, err
+	}
+	child := newSession(r, token.UserID.UUID, newRefreshToken)
+	child.FamilyID = token.FamilyID
+	child.ParentID = uuid.NullUUID{UUID: token.ID, Valid: true}
+	child.ClientID = token.ClientID
+	child.Scope = token.Scope
+	_, err = qtx.CreateRefreshToken(r.Context(), child)
+	if err != nil {
+		return "", database.CreateRefreshTokenParams{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", database.CreateRefreshTokenParams{}, err
+	}
+	return newRefreshToken, child, nil
+}
+
+// revokeStolenFamily ends every token descended from the same login after a
+// rotated token is presented again.
+func (cfg *ApiConfig) revokeStolenFamily(r *http.Request, token database.RefreshToken) {
+	log.Printf("possible refresh token theft: rotated token reused for user %s, revoking session %s", token.UserID.UUID, token.FamilyID)
+	err := cfg.DB.RevokeRefreshTokenFamily(r.Context(), token.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking refresh token family: %v", err)
+	}
+	err = cfg.revokeFamilyAccessTokens(r.Context(), token.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking access tokens: %v", err)
+	}
+}
+
+// clientIP is the address of the direct peer. Forwarded headers are ignored
+// because any client can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func toSession(t database.RefreshToken) Session {
+	return Session{
+		ID:         t.FamilyID,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: nullTimePtr(t.LastUsedAt),
+		ExpiresAt:  t.ExpiresAt,
+		UserAgent:  t.UserAgent,
+		IP:         t.Ip,
+		ClientID:   nullUUIDPtr(t.ClientID),
+		Scope:      t.Scope.String,
+	}
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
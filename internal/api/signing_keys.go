@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+// RunKeyringReloader reloads the signing keys from the database every interval,
+// so keys rotated with `chirpy keys` reach running servers without a restart.
+func (cfg *ApiConfig) RunKeyringReloader(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := cfg.ReloadKeyring(ctx)
+		if err != nil {
+			log.Printf("Error reloading signing keys: %v", err)
+		}
+	}
+}
+
+// ReloadKeyring replaces the keyring with the keys stored in the database.
+// Keys that can't be decrypted are logged and left out. A set of keys that
+// can't sign anything is refused and the current keyring kept.
+func (cfg *ApiConfig) ReloadKeyring(ctx context.Context) error {
+	rows, err := cfg.DB.GetSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+	entries := []auth.KeyringEntry{}
+	canSign := false
+	for _, row := range rows {
+		if row.Status == auth.KeyRetired {
+			continue
+		}
+		key, err := cfg.openSigningKey(row)
+		if err != nil {
+			log.Printf("Error opening signing key %s, skipping it: %v", row.ID, err)
+			continue
+		}
+		entries = append(entries, auth.KeyringEntry{
+			Key:         key,
+			Status:      row.Status,
+			ActivatesAt: row.ActivatesAt,
+		})
+		if row.Status == auth.KeyActive && !row.ActivatesAt.After(time.Now()) {
+			canSign = true
+		}
+	}
+	if !canSign {
+		return fmt.Errorf("no active signing key in the database")
+	}
+	cfg.Keyring.Load(entries)
+	return nil
+}
+
+// SeedSigningKey stores key as the active key if the database has none yet,
+// so the key configured by environment becomes the first entry of the keyring.
+func (cfg *ApiConfig) SeedSigningKey(ctx context.Context, key auth.SigningKey) error {
+	rows, err := cfg.DB.GetSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		return nil
+	}
+	_, err = cfg.createSigningKey(ctx, key, time.Time{})
+	return err
+}
+
+// RotateSigningKey adds a new active key for alg that starts signing after delay.
+// The delay gives every server and JWKS cache time to learn the key first.
+// Earlier keys keep verifying until they are retired.
+func (cfg *ApiConfig) RotateSigningKey(ctx context.Context, alg string, delay time.Duration) (database.SigningKey, error) {
+	key, err := auth.GenerateSigningKey(alg)
+	if err != nil {
+		return database.SigningKey{}, err
+	}
+	return cfg.createSigningKey(ctx, key, time.Now().Add(delay))
+}
+
+// SetSigningKeyStatus moves a key to verify-only or retired. The last key that
+// is signing can't be moved, since ReloadKeyring would refuse the result.
+func (cfg *ApiConfig) SetSigningKeyStatus(ctx context.Context, id, status string) error {
+	if status != auth.KeyActive && status != auth.KeyVerifyOnly && status != auth.KeyRetired {
+		return fmt.Errorf("unknown key status %q", status)
+	}
+	if status != auth.KeyActive {
+		rows, err := cfg.DB.GetSigningKeys(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		signing := 0
+		demotingSigner := false
+		for _, row := range rows {
+			if row.Status != auth.KeyActive || row.ActivatesAt.After(now) {
+				continue
+			}
+			signing++
+			if row.ID == id {
+				demotingSigner = true
+			}
+		}
+		if demotingSigner && signing == 1 {
+			return fmt.Errorf("%s is the only key signing tokens; rotate in a new key and wait for it to activate first", id)
+		}
+	}
+	updated, err := cfg.DB.SetSigningKeyStatus(ctx, database.SetSigningKeyStatusParams{
+		ID:     id,
+		Status: status,
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("no signing key %q", id)
+	}
+	return nil
+}
+
+// ResealSigningKeys encrypts every stored key again with KeyEncryptionKey and
+// returns how many it rewrote. Run it after setting KeyEncryptionKey so the keys
+// no longer depend on SECRET_JWT.
+func (cfg *ApiConfig) ResealSigningKeys(ctx context.Context) (int, error) {
+	rows, err := cfg.DB.GetSigningKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	resealed := 0
+	for _, row := range rows {
+		if row.Status == auth.KeyRetired {
+			continue
+		}
+		key, err := cfg.openSigningKey(row)
+		if err != nil {
+			return resealed, fmt.Errorf("opening signing key %s: %w", row.ID, err)
+		}
+		sealed, err := auth.SealSigningKey(key, cfg.KeyEncryptionKey)
+		if err != nil {
+			return resealed, err
+		}
+		err = cfg.DB.SetSigningKeySealedKey(ctx, database.SetSigningKeySealedKeyParams{
+			ID:        row.ID,
+			SealedKey: sealed,
+		})
+		if err != nil {
+			return resealed, err
+		}
+		resealed++
+	}
+	return resealed, nil
+}
+
+// openSigningKey decrypts a stored key. Keys sealed before KeyEncryptionKey was
+// set were encrypted with SECRET_JWT, so that is tried second.
+func (cfg *ApiConfig) openSigningKey(row database.SigningKey) (auth.SigningKey, error) {
+	key, err := auth.OpenSigningKey(row.ID, row.Alg, row.SealedKey, cfg.KeyEncryptionKey)
+	if err == nil || cfg.SECRET_JWT == cfg.KeyEncryptionKey {
+		return key, err
+	}
+	return auth.OpenSigningKey(row.ID, row.Alg, row.SealedKey, cfg.SECRET_JWT)
+}
+
+func (cfg *ApiConfig) createSigningKey(ctx context.Context, key auth.SigningKey, activatesAt time.Time) (database.SigningKey, error) {
+	sealed, err := auth.SealSigningKey(key, cfg.KeyEncryptionKey)
+	if err != nil {
+		return database.SigningKey{}, err
+	}
+	return cfg.DB.CreateSigningKey(ctx, database.CreateSigningKeyParams{
+		ID:          key.ID,
+		Alg:         key.Alg,
+		SealedKey:   sealed,
+		Status:      auth.KeyActive,
+		ActivatesAt: activatesAt,
+	})
+}
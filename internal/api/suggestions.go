@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+// Suggestion is a suggested account. The score only orders them; it isn't
+// returned, since it is built from other users' activity.
+type Suggestion struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// RunSuggestionsWorker recomputes who-to-follow suggestions every interval so
+// GetSuggestions only has to read the precomputed table.
+func (cfg *ApiConfig) RunSuggestionsWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := cfg.refreshSuggestions(ctx)
+		if err != nil {
+			log.Printf("Error computing suggestions: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *ApiConfig) refreshSuggestions(ctx context.Context) error {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	err = qtx.DeleteUserSuggestions(ctx)
+	if err != nil {
+		return err
+	}
+	err = qtx.ComputeUserSuggestions(ctx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+//
+// HANDLERS
+//
+
+func (cfg *ApiConfig) GetSuggestions(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	suggestions, err := cfg.DB.GetUserSuggestions(r.Context(), database.GetUserSuggestionsParams{
+		UserID: userid,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("Error retrieving suggestions: %v", err)
+		respondWithError(w, 500, "Failed to retrieve suggestions")
+		return
+	}
+
+	returningSuggestions := []Suggestion{}
+	for _, suggestedID := range suggestions {
+		returningSuggestions = append(returningSuggestions, Suggestion{
+			UserID: suggestedID,
+		})
+	}
+	respondWithJSON(w, 200, returningSuggestions)
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/o0n1x/chirpy/internal/auth"
+	"github.com/o0n1x/chirpy/internal/database"
+)
+
+const (
+	loginChallengePurpose = "login_2fa"
+	loginChallengeTTL     = 5 * time.Minute
+	totpIssuer            = "Chirpy"
+	recoveryCodeCount     = 10
+)
+
+//
+// HANDLERS
+//
+
+// EnrollTOTP starts two-factor enrollment. The secret only takes effect once
+// ConfirmTOTP has seen a valid code from it.
+func (cfg *ApiConfig) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	secret, err := auth.MakeTOTPSecret()
+	if err != nil {
+		log.Printf("Error creating TOTP secret: %v", err)
+		respondWithError(w, 500, "Failed to start enrollment")
+		return
+	}
+	updated, err := cfg.DB.SetPendingTOTPSecret(r.Context(), database.SetPendingTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error saving TOTP secret: %v", err)
+		respondWithError(w, 500, "Failed to start enrollment")
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, 409, "two-factor authentication is already enabled")
+		return
+	}
+
+	respondWithJSON(w, 200, struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+func (cfg *ApiConfig) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, 409, "two-factor authentication is already enabled")
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(w, 400, "start enrollment first")
+		return
+	}
+	step, err := auth.ValidateTOTP(user.TotpSecret.String, strings.TrimSpace(params.Code), time.Now())
+	if err != nil {
+		respondWithError(w, 400, "invalid code")
+		return
+	}
+
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Printf("Error creating recovery codes: %v", err)
+		respondWithError(w, 500, "Failed to enable two-factor authentication")
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		respondWithError(w, 500, "Failed to enable two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	enabled, err := qtx.EnableTOTP(r.Context(), database.EnableTOTPParams{
+		ID:           user.ID,
+		TotpLastStep: step,
+	})
+	if err != nil {
+		log.Printf("Error enabling TOTP: %v", err)
+		respondWithError(w, 500, "Failed to enable two-factor authentication")
+		return
+	}
+	if enabled == 0 {
+		respondWithError(w, 409, "two-factor authentication is already enabled")
+		return
+	}
+	err = qtx.DeleteRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error deleting recovery codes: %v", err)
+		respondWithError(w, 500, "Failed to enable two-factor authentication")
+		return
+	}
+	for _, code := range codes {
+		err = qtx.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			CodeHash: auth.HashToken(code),
+			UserID:   user.ID,
+		})
+		if err != nil {
+			log.Printf("Error saving recovery code: %v", err)
+			respondWithError(w, 500, "Failed to enable two-factor authentication")
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing two-factor enrollment: %v", err)
+		respondWithError(w, 500, "Failed to enable two-factor authentication")
+		return
+	}
+
+	// the codes are only stored hashed, so this is the one time they can be shown
+	respondWithJSON(w, 200, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	})
+}
+
+func (cfg *ApiConfig) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("Error parsing header: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	userid, err := auth.ValidateJWT(token, cfg.Keyring)
+	if err != nil {
+		log.Printf("Error validating token: %v", err)
+		respondWithError(w, 401, "Token missing or invalid")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 404, "user not found")
+		return
+	}
+	if !cfg.confirmPassword(w, r, user, params.Password) {
+		return
+	}
+
+	err = cfg.DB.DisableTOTP(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error disabling TOTP: %v", err)
+		respondWithError(w, 500, "Failed to disable two-factor authentication")
+		return
+	}
+	err = cfg.DB.DeleteRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error deleting recovery codes: %v", err)
+	}
+	respondWithJSON(w, 204, nil)
+}
+
+// LoginTwoFactor finishes a login that Login answered with a challenge token.
+// Code is either a current TOTP code or one of the unused recovery codes.
+func (cfg *ApiConfig) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding parameters: %s", err)
+		respondWithError(w, 400, "Invalid JSON in the request body")
+		return
+	}
+
+	userid, _, err := auth.ValidateSignedToken(params.ChallengeToken, loginChallengePurpose, cfg.SECRET_JWT)
+	if err != nil {
+		log.Printf("Error validating login challenge: %v", err)
+		respondWithError(w, 401, "invalid or expired challenge")
+		return
+	}
+	user, err := cfg.DB.GetUserByID(r.Context(), userid)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		respondWithError(w, 401, "invalid or expired challenge")
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		respondWithError(w, 401, "invalid or expired challenge")
+		return
+	}
+
+	// codes are guessable too, so they share the password's failure counters
+	throttleKeys := []string{accountThrottleKey(user.Email), ipThrottleKey(r)}
+	wait, err := cfg.loginLockout(r.Context(), throttleKeys...)
+	if err != nil {
+		log.Printf("Error checking login throttle: %v", err)
+		respondWithError(w, 500, "Failed to verify code")
+		return
+	}
+	if wait > 0 {
+		respondLoginLocked(w, wait)
+		return
+	}
+
+	ok, err := cfg.checkSecondFactor(r, user, params.Code)
+	if err != nil {
+		log.Printf("Error checking second factor: %v", err)
+		respondWithError(w, 500, "Failed to verify code")
+		return
+	}
+	if !ok {
+		cfg.recordLoginFailure(r.Context(), throttleKeys...)
+		respondWithError(w, 401, "invalid code")
+		return
+	}
+	cfg.clearLoginFailures(r.Context(), user.Email)
+	cfg.completeLogin(w, r, user)
+}
+
+//
+// HELPER FUNCTIONS
+//
+
+// checkSecondFactor accepts each TOTP code and each recovery code only once.
+func (cfg *ApiConfig) checkSecondFactor(r *http.Request, user database.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	step, err := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now())
+	if err == nil {
+		used, err := cfg.DB.UseTOTPStep(r.Context(), database.UseTOTPStepParams{
+			ID:           user.ID,
+			TotpLastStep: step,
+		})
+		if err != nil {
+			return false, err
+		}
+		return used == 1, nil
+	}
+
+	used, err := cfg.DB.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+		UserID:   user.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+	if used == 1 {
+		log.Printf("recovery code used by user %s", user.ID)
+	}
+	return used == 1, nil
+}
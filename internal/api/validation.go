@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// normalizeEmail is applied before every lookup or write so addresses are stored
+// one way; the citext column also compares them case-insensitively.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// validateEmail accepts a bare RFC 5322 address, without a display name.
+func validateEmail(email string) error {
+	if email == "" {
+		return errors.New("email is required")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return errors.New("email is not a valid address")
+	}
+	return nil
+}
+
+// respondWithFieldErrors reports validation failures per request field, e.g.
+// {"error": "validation failed", "fields": {"email": "email is required"}}.
+func respondWithFieldErrors(w http.ResponseWriter, fields map[string]string) {
+	respBody := struct {
+		Error  string            `json:"error"`
+		Fields map[string]string `json:"fields"`
+	}{
+		Error:  "validation failed",
+		Fields: fields,
+	}
+	dat, err := json.Marshal(respBody)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+	w.Write(dat)
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// validateUserFields checks the fields a request sets; nil means the field was
+// left out. The returned map is empty when everything is valid.
+func (cfg *ApiConfig) validateUserFields(email, password *string) map[string]string {
+	fields := map[string]string{}
+	if email != nil {
+		err := validateEmail(*email)
+		if err != nil {
+			fields["email"] = err.Error()
+		}
+	}
+	if password != nil {
+		err := cfg.PasswordPolicy.Check(*password)
+		if err != nil {
+			fields["password"] = err.Error()
+		}
+	}
+	return fields
+}
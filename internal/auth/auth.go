@@ -5,12 +5,61 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/alexedwards/argon2id"
 )
 
-func HashPassword(password string) (string, error) {
-	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+// HashParams are the argon2id costs new password hashes are made with. Hashes
+// keep the parameters they were made with, so changing these only affects new hashes.
+type HashParams struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultHashParams are 64 MiB, one pass and two lanes. argon2id.DefaultParams
+// uses one lane per CPU, which would make hosts of different sizes rehash each
+// other's work, so parallelism is fixed instead.
+func DefaultHashParams() HashParams {
+	return HashParams{
+		Memory:      argon2id.DefaultParams.Memory,
+		Iterations:  argon2id.DefaultParams.Iterations,
+		Parallelism: 2,
+	}
+}
+
+// Validate rejects parameters argon2id can't use.
+func (p HashParams) Validate() error {
+	if p.Iterations < 1 {
+		return fmt.Errorf("iterations must be at least 1")
+	}
+	if p.Parallelism < 1 {
+		return fmt.Errorf("parallelism must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Parallelism) {
+		return fmt.Errorf("memory must be at least 8 KiB per lane")
+	}
+	return nil
+}
+
+func (p HashParams) String() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+}
+
+func (p HashParams) argon2id() *argon2id.Params {
+	return &argon2id.Params{
+		Memory:      p.Memory,
+		Iterations:  p.Iterations,
+		Parallelism: p.Parallelism,
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	}
+}
+
+func HashPassword(password string, params HashParams) (string, error) {
+	hash, err := argon2id.CreateHash(password, params.argon2id())
 	if err != nil {
 		log.Printf("Error hashing password: %v", err)
 		return "", err
@@ -18,6 +67,61 @@ func HashPassword(password string) (string, error) {
 	return hash, nil
 }
 
+// NeedsRehash reports whether hash was made with costs other than params, so
+// the password should be hashed again the next time it is known.
+func NeedsRehash(hash string, params HashParams) bool {
+	current, _, _, err := argon2id.DecodeHash(hash)
+	if err != nil {
+		return false
+	}
+	want := params.argon2id()
+	return current.Memory != want.Memory || current.Iterations != want.Iterations ||
+		current.Parallelism != want.Parallelism || current.KeyLength != want.KeyLength
+}
+
+// CalibrateHashParams finds the most iterations at the given memory and
+// parallelism that hash a password in about target on this host. If one pass
+// is already slower than target, memory is halved until it isn't, down to 8 MiB.
+// It returns the parameters and the time one hash with them took.
+func CalibrateHashParams(target time.Duration, memory uint32, parallelism uint8) (HashParams, time.Duration, error) {
+	const minMemory = 8 * 1024
+	params := HashParams{Memory: memory, Iterations: 1, Parallelism: parallelism}
+	err := params.Validate()
+	if err != nil {
+		return HashParams{}, 0, err
+	}
+
+	elapsed := timeHash(params)
+	for elapsed > target && params.Memory/2 >= minMemory {
+		params.Memory /= 2
+		elapsed = timeHash(params)
+	}
+	for {
+		next := params
+		next.Iterations++
+		nextElapsed := timeHash(next)
+		if nextElapsed > target {
+			return params, elapsed, nil
+		}
+		params, elapsed = next, nextElapsed
+	}
+}
+
+// timeHash is the fastest of three hashes, which leaves out warm-up and
+// scheduling noise.
+func timeHash(params HashParams) time.Duration {
+	fastest := time.Duration(0)
+	for range 3 {
+		start := time.Now()
+		argon2id.CreateHash("chirpy-calibration", params.argon2id())
+		elapsed := time.Since(start)
+		if fastest == 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+	}
+	return fastest
+}
+
 func CheckPasswordHash(password, hash string) (bool, error) {
 	match, err := argon2id.ComparePasswordAndHash(password, hash)
 	if err != nil {
@@ -1,7 +1,13 @@
 package auth
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,7 +24,7 @@ func TestHash(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			hash, err := HashPassword(tc.password)
+			hash, err := HashPassword(tc.password, DefaultHashParams())
 			if err != nil {
 				t.Errorf("Failed to hash: %v\n", err)
 				return
@@ -60,6 +66,58 @@ func TestCheckPassword(t *testing.T) {
 
 }
 
+func TestNeedsRehash(t *testing.T) {
+	current := HashParams{Memory: 8 * 1024, Iterations: 2, Parallelism: 1}
+	cases := map[string]struct {
+		params HashParams
+		rehash bool
+	}{
+		"same params":       {current, false},
+		"more memory":       {HashParams{Memory: 16 * 1024, Iterations: 2, Parallelism: 1}, true},
+		"fewer iterations":  {HashParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1}, true},
+		"other parallelism": {HashParams{Memory: 8 * 1024, Iterations: 2, Parallelism: 2}, true},
+	}
+
+	hash, err := HashPassword("password", current)
+	if err != nil {
+		t.Fatalf("Failed to hash: %v\n", err)
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if NeedsRehash(hash, tc.params) != tc.rehash {
+				t.Errorf("NeedsRehash with %s should be %v\n", tc.params, tc.rehash)
+			}
+		})
+	}
+	if NeedsRehash("not-a-hash", current) {
+		t.Error("NeedsRehash should leave hashes it can't read alone\n")
+	}
+}
+
+func TestCalibrateHashParams(t *testing.T) {
+	params, elapsed, err := CalibrateHashParams(50*time.Millisecond, 8*1024, 1)
+	if err != nil {
+		t.Fatalf("Failed to calibrate: %v\n", err)
+	}
+	if params.Validate() != nil || params.Memory != 8*1024 || params.Parallelism != 1 {
+		t.Errorf("unexpected params %s\n", params)
+	}
+	// only a single pass is allowed to come out slower than the target
+	if params.Iterations > 1 && elapsed > 50*time.Millisecond {
+		t.Errorf("%s took %s, over the target\n", params, elapsed)
+	}
+	if _, _, err := CalibrateHashParams(time.Millisecond, 4, 1); err == nil {
+		t.Error("CalibrateHashParams accepted memory below 8 KiB per lane\n")
+	}
+}
+
+func BenchmarkHashPassword(b *testing.B) {
+	params := DefaultHashParams()
+	for b.Loop() {
+		HashPassword("correct horse battery staple", params)
+	}
+}
+
 func TestJWT(t *testing.T) {
 	cases := map[string]struct {
 		userID uuid.UUID
@@ -72,12 +130,13 @@ func TestJWT(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			token, err := MakeJWT(tc.userID, tc.secret, time.Minute)
+			keyring := NewKeyring(NewHMACKey("default", tc.secret))
+			token, err := MakeJWT(tc.userID, uuid.New(), RoleUser, keyring, time.Minute)
 			if err != nil {
 				t.Errorf("Failed to generate token: %v\n", err)
 				return
 			}
-			user, err := ValidateJWT(token, tc.secret)
+			user, err := ValidateJWT(token, keyring)
 			if err != nil {
 				t.Errorf("Failed to validate token: %v\n", err)
 				return
@@ -119,3 +178,468 @@ func TestGetBearerToken(t *testing.T) {
 	}
 
 }
+
+func TestSignedToken(t *testing.T) {
+	cases := map[string]struct {
+		userID uuid.UUID
+		data   string
+		secret string
+	}{
+		"simple":  {uuid.New(), "user@example.com", "AssumeItsASecret"},
+		"no data": {uuid.New(), "", "AssumeItsASecret"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			token, err := MakeSignedToken("email_verification", tc.userID, tc.data, tc.secret, time.Minute)
+			if err != nil {
+				t.Errorf("Failed to generate token: %v\n", err)
+				return
+			}
+			user, data, err := ValidateSignedToken(token, "email_verification", tc.secret)
+			if err != nil {
+				t.Errorf("Failed to validate token: %v\n", err)
+				return
+			}
+			if user != tc.userID || data != tc.data {
+				t.Errorf("got %v %q, want %v %q\n", user, data, tc.userID, tc.data)
+				return
+			}
+			_, _, err = ValidateSignedToken(token, "password_reset", tc.secret)
+			if err == nil {
+				t.Error("token was accepted for another purpose\n")
+			}
+			_, err = ValidateJWT(token, NewKeyring(NewHMACKey("default", tc.secret)))
+			if err == nil {
+				t.Error("token was accepted as an access token\n")
+			}
+		})
+	}
+
+}
+
+func TestHashToken(t *testing.T) {
+	cases := map[string]struct {
+		token string
+		hash  string
+	}{
+		"simple": {"token", "3c469e9d6c5875d37a43f353d4f88e61fcf812c66eee3457465a40b0da4153e0"},
+		"empty":  {"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			hash := HashToken(tc.token)
+			if hash != tc.hash {
+				t.Errorf("hash %v does not equal expected hash %v\n", hash, tc.hash)
+			}
+		})
+	}
+
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	cases := map[string]struct {
+		password string
+		ok       bool
+	}{
+		"strong":     {"correct horse battery staple", true},
+		"mixed":      {"Tr0ub4dor&3", true},
+		"empty":      {"", false},
+		"too short":  {"aB3$", false},
+		"banned":     {"Password123", false},
+		"repeated":   {"aaaaaaaaaaaa", false},
+		"sequential": {"abcdefghijkl", false},
+	}
+
+	policy := DefaultPasswordPolicy()
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := policy.Check(tc.password)
+			if (err == nil) != tc.ok {
+				t.Errorf("Check(%q) returned %v, want ok=%v\n", tc.password, err, tc.ok)
+			}
+		})
+	}
+
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B vectors, truncated to 6 digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	cases := map[string]struct {
+		time time.Time
+		code string
+	}{
+		"59":         {time.Unix(59, 0), "287082"},
+		"1111111109": {time.Unix(1111111109, 0), "081804"},
+		"1234567890": {time.Unix(1234567890, 0), "005924"},
+		"2000000000": {time.Unix(2000000000, 0), "279037"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, err := TOTPCode(secret, TOTPStep(tc.time))
+			if err != nil {
+				t.Errorf("Failed to generate code: %v\n", err)
+				return
+			}
+			if code != tc.code {
+				t.Errorf("code %v does not equal expected code %v\n", code, tc.code)
+				return
+			}
+			_, err = ValidateTOTP(secret, tc.code, tc.time.Add(totpPeriod*time.Second))
+			if err != nil {
+				t.Errorf("code from the previous step was rejected: %v\n", err)
+			}
+			_, err = ValidateTOTP(secret, tc.code, tc.time.Add(3*totpPeriod*time.Second))
+			if err == nil {
+				t.Error("code from three steps ago was accepted\n")
+			}
+		})
+	}
+
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("Failed to generate recovery codes: %v\n", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || seen[code] {
+			t.Errorf("bad or repeated recovery code %q\n", code)
+		}
+		seen[code] = true
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+		if NormalizeRecoveryCode(typed) != code {
+			t.Errorf("%q did not normalize to %q\n", typed, code)
+		}
+	}
+
+}
+
+func TestKeyring(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v\n", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v\n", err)
+	}
+	cases := map[string]struct {
+		key SigningKey
+		// published in the JWKS
+		public bool
+	}{
+		"HS256": {NewHMACKey("hs", "AssumeItsASecret"), false},
+		"RS256": {NewRSAKey("rs", rsaKey), true},
+		"EdDSA": {NewEd25519Key("ed", edKey), true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			userID := uuid.New()
+			token, err := MakeJWT(userID, uuid.New(), RoleUser, NewKeyring(tc.key), time.Minute)
+			if err != nil {
+				t.Errorf("Failed to generate token: %v\n", err)
+				return
+			}
+
+			// a keyring that only holds the key as verify-only still accepts it
+			verifier := NewKeyring(NewHMACKey("other", "AnotherSecret"), tc.key)
+			user, err := ValidateJWT(token, verifier)
+			if err != nil {
+				t.Errorf("Failed to validate token: %v\n", err)
+				return
+			}
+			if user != userID {
+				t.Errorf("uuid %v does not equal expected uuid %v\n", user, userID)
+			}
+
+			_, err = ValidateJWT(token, NewKeyring(NewHMACKey("other", "AnotherSecret")))
+			if err == nil {
+				t.Error("token was accepted by a keyring without its key\n")
+			}
+
+			jwks := verifier.JWKS()
+			published := len(jwks.Keys) == 1 && jwks.Keys[0].Kid == tc.key.ID
+			if published != tc.public {
+				t.Errorf("JWKS %+v, want key published: %v\n", jwks, tc.public)
+			}
+		})
+	}
+
+}
+
+func TestKeyringRejectsAlgorithmMismatch(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v\n", err)
+	}
+	// an HS256 token whose kid names an Ed25519 key must not be checked as HMAC
+	forged := NewHMACKey("ed", "AssumeItsASecret")
+	token, err := MakeJWT(uuid.New(), uuid.New(), RoleUser, NewKeyring(forged), time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v\n", err)
+	}
+	_, err = ValidateJWT(token, NewKeyring(NewEd25519Key("ed", edKey)))
+	if err == nil {
+		t.Error("token signed with the wrong algorithm was accepted\n")
+	}
+
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v\n", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v\n", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	key, err := ParsePrivateKeyPEM("", data)
+	if err != nil {
+		t.Fatalf("Failed to parse key: %v\n", err)
+	}
+	thumbprint, err := NewEd25519Key("", edKey).Thumbprint()
+	if err != nil {
+		t.Fatalf("Failed to compute thumbprint: %v\n", err)
+	}
+	if key.Alg != AlgEdDSA || key.ID != thumbprint {
+		t.Errorf("got %s key %q, want EdDSA key %q\n", key.Alg, key.ID, thumbprint)
+	}
+
+}
+
+func TestSealSigningKey(t *testing.T) {
+	cases := map[string]struct {
+		alg string
+	}{
+		"HS256": {AlgHS256},
+		"RS256": {AlgRS256},
+		"EdDSA": {AlgEdDSA},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			key, err := GenerateSigningKey(tc.alg)
+			if err != nil {
+				t.Errorf("Failed to generate key: %v\n", err)
+				return
+			}
+			sealed, err := SealSigningKey(key, "AssumeItsASecret")
+			if err != nil {
+				t.Errorf("Failed to seal key: %v\n", err)
+				return
+			}
+			opened, err := OpenSigningKey(key.ID, tc.alg, sealed, "AssumeItsASecret")
+			if err != nil {
+				t.Errorf("Failed to open key: %v\n", err)
+				return
+			}
+
+			token, err := MakeJWT(uuid.New(), uuid.New(), RoleUser, NewKeyring(key), time.Minute)
+			if err != nil {
+				t.Errorf("Failed to generate token: %v\n", err)
+				return
+			}
+			_, err = ValidateJWT(token, NewKeyring(opened))
+			if err != nil {
+				t.Errorf("opened key did not verify a token from the original: %v\n", err)
+			}
+
+			_, err = OpenSigningKey(key.ID, tc.alg, sealed, "AnotherSecret")
+			if err == nil {
+				t.Error("key was opened with the wrong secret\n")
+			}
+			_, err = OpenSigningKey("other", tc.alg, sealed, "AssumeItsASecret")
+			if err == nil {
+				t.Error("key was opened under another kid\n")
+			}
+		})
+	}
+
+}
+
+func TestKeyringStatuses(t *testing.T) {
+	oldKey := NewHMACKey("old", "OldSecret")
+	currentKey := NewHMACKey("current", "CurrentSecret")
+	nextKey := NewHMACKey("next", "NextSecret")
+	retiredKey := NewHMACKey("retired", "RetiredSecret")
+	keyring := NewKeyring(currentKey)
+	keyring.Load([]KeyringEntry{
+		{Key: oldKey, Status: KeyActive, ActivatesAt: time.Now().Add(-2 * time.Hour)},
+		{Key: currentKey, Status: KeyActive, ActivatesAt: time.Now().Add(-time.Hour)},
+		{Key: nextKey, Status: KeyActive, ActivatesAt: time.Now().Add(time.Hour)},
+		{Key: retiredKey, Status: KeyRetired},
+	})
+
+	signer, err := keyring.signingKey()
+	if err != nil || signer.ID != "current" {
+		t.Errorf("signing key is %q (%v), want %q\n", signer.ID, err, "current")
+	}
+	cases := map[string]struct {
+		key      SigningKey
+		accepted bool
+	}{
+		"superseded active": {oldKey, true},
+		"current":           {currentKey, true},
+		"not yet active":    {nextKey, true},
+		"retired":           {retiredKey, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			token, err := MakeJWT(uuid.New(), uuid.New(), RoleUser, NewKeyring(tc.key), time.Minute)
+			if err != nil {
+				t.Errorf("Failed to generate token: %v\n", err)
+				return
+			}
+			_, err = ValidateJWT(token, keyring)
+			if (err == nil) != tc.accepted {
+				t.Errorf("ValidateJWT returned %v, want accepted=%v\n", err, tc.accepted)
+			}
+		})
+	}
+
+}
+
+func TestDenylist(t *testing.T) {
+	keyring := NewKeyring(NewHMACKey("default", "AssumeItsASecret"))
+	revokedJTI := uuid.New()
+	keyring.Denylist.Add(revokedJTI.String(), time.Now().Add(time.Minute))
+
+	cases := map[string]struct {
+		jti      uuid.UUID
+		accepted bool
+	}{
+		"not revoked": {uuid.New(), true},
+		"revoked":     {revokedJTI, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			token, err := MakeJWT(uuid.New(), tc.jti, RoleUser, keyring, time.Minute)
+			if err != nil {
+				t.Errorf("Failed to generate token: %v\n", err)
+				return
+			}
+			_, err = ValidateJWT(token, keyring)
+			if (err == nil) != tc.accepted {
+				t.Errorf("ValidateJWT returned %v, want accepted=%v\n", err, tc.accepted)
+			}
+		})
+	}
+
+	keyring.Denylist.Prune(time.Now().Add(2 * time.Minute))
+	if keyring.Denylist.Contains(revokedJTI.String()) {
+		t.Error("expired entry was not pruned\n")
+	}
+}
+
+func TestScopes(t *testing.T) {
+	cases := map[string]struct {
+		scopes  []string
+		parsed  string
+		wantErr bool
+	}{
+		"single":     {[]string{ScopeChirpsRead}, "chirps:read", false},
+		"sorted":     {[]string{ScopeProfileWrite, ScopeChirpsWrite}, "chirps:write profile:write", false},
+		"duplicates": {[]string{ScopeChirpsRead, ScopeChirpsRead}, "chirps:read", false},
+		"unknown":    {[]string{"admin"}, "", true},
+		"empty":      {[]string{}, "", true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := ParseScopes(tc.scopes)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ParseScopes returned %v, want error=%v\n", err, tc.wantErr)
+				return
+			}
+			if parsed != tc.parsed {
+				t.Errorf("ParseScopes returned %q, want %q\n", parsed, tc.parsed)
+			}
+		})
+	}
+
+	if !HasScope("chirps:read chirps:write", ScopeChirpsWrite) || HasScope("chirps:read", ScopeChirpsWrite) {
+		t.Error("HasScope did not match the scope list\n")
+	}
+}
+
+func TestRoles(t *testing.T) {
+	cases := map[string]struct {
+		role     string
+		required string
+		allowed  bool
+	}{
+		"admin as moderator": {RoleAdmin, RoleModerator, true},
+		"moderator as admin": {RoleModerator, RoleAdmin, false},
+		"user as user":       {RoleUser, RoleUser, true},
+		"user as moderator":  {RoleUser, RoleModerator, false},
+		"unknown as user":    {"root", RoleUser, false},
+		"missing as user":    {"", RoleUser, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if RoleAtLeast(tc.role, tc.required) != tc.allowed {
+				t.Errorf("RoleAtLeast(%q, %q) should be %v\n", tc.role, tc.required, tc.allowed)
+			}
+		})
+	}
+
+	keyring := NewKeyring(NewHMACKey("default", "AssumeItsASecret"))
+	token, err := MakeJWT(uuid.New(), uuid.New(), RoleModerator, keyring, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v\n", err)
+	}
+	claims, err := ParseAccessToken(token, keyring)
+	if err != nil || claims.Role != RoleModerator {
+		t.Errorf("role claim is %q (%v), want %q\n", claims.Role, err, RoleModerator)
+	}
+}
+
+func TestPKCE(t *testing.T) {
+	// RFC 7636 appendix B
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	cases := map[string]struct {
+		verifier string
+		valid    bool
+	}{
+		"rfc vector":     {"dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", true},
+		"wrong verifier": {"dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXl", false},
+		"too short":      {"dBjftJeZ4CVP", false},
+		"bad characters": {"dBjftJeZ4CVP+mB92K27uhbUJU1p1r/wW1gFWFOEjXk", false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if VerifyPKCE(tc.verifier, challenge) != tc.valid {
+				t.Errorf("VerifyPKCE(%q) should be %v\n", tc.verifier, tc.valid)
+			}
+		})
+	}
+	if !ValidPKCEChallenge(challenge) || ValidPKCEChallenge("plain-challenge") {
+		t.Error("ValidPKCEChallenge did not check the challenge shape\n")
+	}
+}
+
+func TestScopedJWT(t *testing.T) {
+	keyring := NewKeyring(NewHMACKey("default", "AssumeItsASecret"))
+	userID := uuid.New()
+	token, err := MakeScopedJWT(userID, uuid.New(), "client-1", ScopeChirpsRead, keyring, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v\n", err)
+	}
+	claims, err := ParseAccessToken(token, keyring)
+	if err != nil || claims.Subject != userID.String() || claims.Scope != ScopeChirpsRead || claims.Role != "" {
+		t.Errorf("unexpected claims %+v (%v)\n", claims, err)
+	}
+	// first-party endpoints must not accept a third-party token
+	if _, err := ValidateJWT(token, keyring); err == nil {
+		t.Error("ValidateJWT accepted a token scoped to an OAuth client\n")
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Denylist holds the IDs of access tokens revoked before they expire.
+// Entries are only needed until the token would have expired anyway.
+type Denylist struct {
+	mu   sync.RWMutex
+	jtis map[string]time.Time
+}
+
+func NewDenylist() *Denylist {
+	return &Denylist{jtis: map[string]time.Time{}}
+}
+
+// Add revokes the token with the given jti until expiresAt.
+func (d *Denylist) Add(jti string, expiresAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.jtis[jti] = expiresAt
+}
+
+func (d *Denylist) Contains(jti string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, ok := d.jtis[jti]
+	return ok
+}
+
+// Prune drops entries for tokens that have expired by now.
+func (d *Denylist) Prune(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for jti, expiresAt := range d.jtis {
+		if expiresAt.Before(now) {
+			delete(d.jtis, jti)
+		}
+	}
+}
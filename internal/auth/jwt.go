@@ -10,37 +10,89 @@ import (
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+// AccessClaims are the claims of an access token. Tokens issued to a
+// third-party OAuth client name it in ClientID and are limited to Scope.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	Role     string `json:"role,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// MakeJWT signs an access token with the keyring's active key, named in the kid header.
+// jti identifies the token so it can be revoked before it expires.
+func MakeJWT(userID, jti uuid.UUID, role string, keyring *Keyring, expiresIn time.Duration) (string, error) {
+	return signAccessToken(AccessClaims{
+		RegisteredClaims: accessRegisteredClaims(userID, jti, expiresIn),
+		Role:             role,
+	}, keyring)
+}
+
+// MakeScopedJWT signs an access token for an OAuth client. It carries no role,
+// and only endpoints that check for one of its scopes accept it.
+func MakeScopedJWT(userID, jti uuid.UUID, clientID, scope string, keyring *Keyring, expiresIn time.Duration) (string, error) {
+	return signAccessToken(AccessClaims{
+		RegisteredClaims: accessRegisteredClaims(userID, jti, expiresIn),
+		ClientID:         clientID,
+		Scope:            scope,
+	}, keyring)
+}
+
+func accessRegisteredClaims(userID, jti uuid.UUID, expiresIn time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
 		Issuer:    "chirpy",
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
 		Subject:   userID.String(),
-	})
+		ID:        jti.String(),
+	}
+}
 
-	tokenstring, err := token.SignedString([]byte(tokenSecret))
+func signAccessToken(claims AccessClaims, keyring *Keyring) (string, error) {
+	key, err := keyring.signingKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+
+	tokenstring, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", err
 	}
 	return tokenstring, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(t *jwt.Token) (any, error) {
-		return []byte(tokenSecret), nil
-	})
+// ValidateJWT verifies a first-party access token and returns the user it was
+// issued to. Tokens scoped to an OAuth client are refused.
+func ValidateJWT(tokenString string, keyring *Keyring) (uuid.UUID, error) {
+	claims, err := ParseAccessToken(tokenString, keyring)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
-	subject, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.UUID{}, err
+	if claims.ClientID != "" {
+		return uuid.UUID{}, fmt.Errorf("token is scoped to OAuth client %s", claims.ClientID)
 	}
-	user, err := uuid.Parse(subject)
+	return uuid.Parse(claims.Subject)
+}
+
+// ParseAccessToken verifies an access token with the keyring key its kid header
+// names, rejects tokens on the keyring's denylist and returns the claims.
+func ParseAccessToken(tokenString string, keyring *Keyring) (AccessClaims, error) {
+	claims := AccessClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, keyring.keyFunc,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}), jwt.WithIssuer("chirpy"))
 	if err != nil {
-		return uuid.UUID{}, err
+		return AccessClaims{}, err
+	}
+	// a token without a jti could never be revoked
+	if claims.ID == "" {
+		return AccessClaims{}, fmt.Errorf("token has no jti")
+	}
+	if keyring.Denylist.Contains(claims.ID) {
+		return AccessClaims{}, fmt.Errorf("token has been revoked")
 	}
-	return user, nil
+	return claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signing algorithms a Keyring can hold
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is one access-token key, named by the kid header of the tokens it signs.
+type SigningKey struct {
+	ID  string
+	Alg string
+	// []byte for HS256, *rsa.PrivateKey for RS256, ed25519.PrivateKey for EdDSA
+	signKey any
+	// the same secret for HS256, otherwise the public half
+	verifyKey any
+}
+
+func NewHMACKey(id, secret string) SigningKey {
+	return SigningKey{ID: id, Alg: AlgHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+}
+
+func NewRSAKey(id string, priv *rsa.PrivateKey) SigningKey {
+	return SigningKey{ID: id, Alg: AlgRS256, signKey: priv, verifyKey: &priv.PublicKey}
+}
+
+func NewEd25519Key(id string, priv ed25519.PrivateKey) SigningKey {
+	return SigningKey{ID: id, Alg: AlgEdDSA, signKey: priv, verifyKey: priv.Public()}
+}
+
+// ParsePrivateKeyPEM reads a PKCS #8 RSA or Ed25519 private key. An empty id
+// is replaced by the key's RFC 7638 thumbprint.
+func ParsePrivateKeyPEM(id string, data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	var key SigningKey
+	switch priv := parsed.(type) {
+	case *rsa.PrivateKey:
+		key = NewRSAKey(id, priv)
+	case ed25519.PrivateKey:
+		key = NewEd25519Key(id, priv)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+	if key.ID == "" {
+		key.ID, err = key.Thumbprint()
+		if err != nil {
+			return SigningKey{}, err
+		}
+	}
+	return key, nil
+}
+
+// JWK is the public half of a key as published in /.well-known/jwks.json.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK describes the verification key. HMAC keys are secret and have none.
+func (k SigningKey) PublicJWK() (JWK, bool) {
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: k.Alg,
+			Kid: k.ID,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Use: "sig",
+			Alg: k.Alg,
+			Kid: k.ID,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, true
+	}
+	return JWK{}, false
+}
+
+// PublicKey decodes an RSA, P-256 or Ed25519 verification key, such as one
+// published by an OpenID Connect provider.
+func (j JWK) PublicKey() (any, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		// the uncompressed point encoding, which ecdsa.ParseUncompressedPublicKey validates
+		point := append([]byte{4}, append(leftPad(x, 32), leftPad(y, 32)...)...)
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 key has %d bytes", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// Thumbprint is the RFC 7638 SHA-256 thumbprint of the public key.
+func (k SigningKey) Thumbprint() (string, error) {
+	jwk, ok := k.PublicJWK()
+	if !ok {
+		return "", fmt.Errorf("%s keys have no public thumbprint", k.Alg)
+	}
+	// the required members in lexicographic order, as the RFC specifies
+	var members any
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	dat, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(dat)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func (k SigningKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Alg)
+}
+
+// key statuses: active keys sign once their activation time has passed, verify-only
+// keys are still accepted and published, retired keys are neither
+const (
+	KeyActive     = "active"
+	KeyVerifyOnly = "verify"
+	KeyRetired    = "retired"
+)
+
+type KeyringEntry struct {
+	Key         SigningKey
+	Status      string
+	ActivatesAt time.Time
+}
+
+// Keyring holds the keys access tokens are signed and verified with, and the
+// denylist of tokens revoked early. It is safe for concurrent use and can be
+// reloaded while in use.
+type Keyring struct {
+	mu       sync.RWMutex
+	entries  map[string]KeyringEntry
+	Denylist *Denylist
+}
+
+// NewKeyring signs with active; tokens from the verifyOnly keys are still accepted.
+func NewKeyring(active SigningKey, verifyOnly ...SigningKey) *Keyring {
+	entries := []KeyringEntry{{Key: active, Status: KeyActive}}
+	for _, key := range verifyOnly {
+		entries = append(entries, KeyringEntry{Key: key, Status: KeyVerifyOnly})
+	}
+	k := &Keyring{Denylist: NewDenylist()}
+	k.Load(entries)
+	return k
+}
+
+// Load replaces every key in the keyring. The denylist is kept.
+func (k *Keyring) Load(entries []KeyringEntry) {
+	keys := map[string]KeyringEntry{}
+	for _, entry := range entries {
+		keys[entry.Key.ID] = entry
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.entries = keys
+}
+
+// signingKey is the active key with the latest activation time that has passed.
+// Older active keys keep verifying until they are retired.
+func (k *Keyring) signingKey() (SigningKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := time.Now()
+	var signer *KeyringEntry
+	for _, entry := range k.entries {
+		if entry.Status != KeyActive || entry.ActivatesAt.After(now) {
+			continue
+		}
+		if signer == nil || entry.ActivatesAt.After(signer.ActivatesAt) {
+			signer = &entry
+		}
+	}
+	if signer == nil {
+		return SigningKey{}, fmt.Errorf("no active signing key")
+	}
+	return signer.Key, nil
+}
+
+func (k *Keyring) lookup(kid string) (SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	entry, ok := k.entries[kid]
+	if !ok || entry.Status == KeyRetired {
+		return SigningKey{}, false
+	}
+	return entry.Key, true
+}
+
+// JWKS lists the public keys for other services to verify tokens with, sorted by kid.
+// Keys waiting for their activation time are included so caches have them first.
+func (k *Keyring) JWKS() JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, entry := range k.entries {
+		if entry.Status == KeyRetired {
+			continue
+		}
+		if jwk, ok := entry.Key.PublicJWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// keyFunc picks the verification key named by the token's kid header and
+// makes sure the token uses that key's algorithm.
+func (k *Keyring) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := k.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if t.Method.Alg() != key.Alg {
+		return nil, fmt.Errorf("token algorithm %s does not match key %q", t.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+)
+
+const keyMaterialPurpose = "signing_keys"
+
+// GenerateSigningKey creates a new key for alg. Asymmetric keys are named by
+// their thumbprint, HMAC keys by a random ID.
+func GenerateSigningKey(alg string) (SigningKey, error) {
+	switch alg {
+	case AlgHS256:
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		id := make([]byte, 8)
+		_, err = rand.Read(id)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		return NewHMACKey("hs-"+hex.EncodeToString(id), string(secret)), nil
+	case AlgRS256:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		key := NewRSAKey("", priv)
+		key.ID, err = key.Thumbprint()
+		return key, err
+	case AlgEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		key := NewEd25519Key("", priv)
+		key.ID, err = key.Thumbprint()
+		return key, err
+	}
+	return SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", alg)
+}
+
+// SealSigningKey serializes the private key and encrypts it with AES-GCM under
+// a key derived from secret, so a database dump alone can't sign tokens.
+func SealSigningKey(key SigningKey, secret string) (string, error) {
+	var plaintext []byte
+	switch priv := key.signKey.(type) {
+	case []byte:
+		plaintext = priv
+	case *rsa.PrivateKey, ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return "", err
+		}
+		plaintext = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	default:
+		return "", fmt.Errorf("unsupported private key type %T", priv)
+	}
+
+	gcm, err := keyMaterialCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	// the kid is bound as additional data so sealed keys can't be swapped between rows
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(key.ID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenSigningKey reverses SealSigningKey.
+func OpenSigningKey(id, alg, sealed, secret string) (SigningKey, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	gcm, err := keyMaterialCipher(secret)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return SigningKey{}, fmt.Errorf("sealed key %q is too short", id)
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(id))
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("opening key %q: %w", id, err)
+	}
+
+	if alg == AlgHS256 {
+		return NewHMACKey(id, string(plaintext)), nil
+	}
+	key, err := ParsePrivateKeyPEM(id, plaintext)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	if key.Alg != alg {
+		return SigningKey{}, fmt.Errorf("key %q is %s, not %s", id, key.Alg, alg)
+	}
+	return key, nil
+}
+
+func keyMaterialCipher(secret string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(purposeKey(keyMaterialPurpose, secret))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//go:embed banned_passwords.txt
+var defaultBannedPasswords string
+
+// PasswordPolicy decides which passwords users may choose.
+type PasswordPolicy struct {
+	MinLength int
+	// MinEntropy is the lowest EstimateEntropy result accepted, in bits
+	MinEntropy float64
+	// Banned holds lowercased passwords that are never accepted
+	Banned map[string]bool
+}
+
+// DefaultPasswordPolicy requires 8 characters, about 35 bits of entropy and
+// rejects the built-in list of common passwords.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:  8,
+		MinEntropy: 35,
+		Banned:     parseBannedPasswords(defaultBannedPasswords),
+	}
+}
+
+// Check returns an error describing the first rule the password breaks.
+func (p PasswordPolicy) Check(password string) error {
+	if password == "" {
+		return fmt.Errorf("password is required")
+	}
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if p.Banned[strings.ToLower(password)] {
+		return fmt.Errorf("password is too common")
+	}
+	if EstimateEntropy(password) < p.MinEntropy {
+		return fmt.Errorf("password is too easy to guess")
+	}
+	return nil
+}
+
+// LoadBannedPasswords adds the passwords in path, one per line, to the banned list.
+func (p *PasswordPolicy) LoadBannedPasswords(path string) error {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if p.Banned == nil {
+		p.Banned = map[string]bool{}
+	}
+	for password := range parseBannedPasswords(string(dat)) {
+		p.Banned[password] = true
+	}
+	return nil
+}
+
+// EstimateEntropy gives a rough strength in bits: the size of the character
+// pool the password draws from, counted only for characters that don't just
+// repeat or continue a sequence ("aaa", "abc", "321") from the previous one.
+func EstimateEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	counted := 0
+	var prev rune
+	for i, c := range []rune(password) {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c < unicode.MaxASCII && unicode.IsPrint(c):
+			symbol = true
+		default:
+			other = true
+		}
+		if i == 0 || (c != prev && c != prev+1 && c != prev-1) {
+			counted++
+		}
+		prev = c
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(counted) * math.Log2(float64(pool))
+}
+
+func parseBannedPasswords(list string) map[string]bool {
+	banned := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			banned[strings.ToLower(line)] = true
+		}
+	}
+	return banned
+}
@@ -0,0 +1,21 @@
+package auth
+
+// user roles, each allowed everything the ones before it are
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants required. Unknown roles grant nothing.
+func RoleAtLeast(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// scopes a personal access token can be limited to
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+)
+
+var knownScopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileWrite}
+
+// SupportedScopes lists every scope a token can be limited to.
+func SupportedScopes() []string {
+	return slices.Clone(knownScopes)
+}
+
+// PersonalAccessTokenPrefix marks personal access tokens so they are never
+// mistaken for JWTs, and so leaked ones are easy to search for.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+// ParseScopes checks a list of scopes and returns it as the space-separated
+// string stored with a token, sorted and without duplicates.
+func ParseScopes(scopes []string) (string, error) {
+	if len(scopes) == 0 {
+		return "", fmt.Errorf("at least one scope is required")
+	}
+	parsed := []string{}
+	for _, scope := range scopes {
+		if !slices.Contains(knownScopes, scope) {
+			return "", fmt.Errorf("unknown scope %q", scope)
+		}
+		if !slices.Contains(parsed, scope) {
+			parsed = append(parsed, scope)
+		}
+	}
+	slices.Sort(parsed)
+	return strings.Join(parsed, " "), nil
+}
+
+// HasScope reports whether a space-separated scope string grants scope.
+func HasScope(scopes, scope string) bool {
+	return slices.Contains(strings.Fields(scopes), scope)
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type signedClaims struct {
+	Data string `json:"data,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// MakeSignedToken creates a short-lived token that is only good for one purpose, such as
+// verifying an email address. The signing key is derived from the secret and the purpose,
+// so a token made for one purpose is never accepted for another or as an access token.
+func MakeSignedToken(purpose string, userID uuid.UUID, data, secret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, signedClaims{
+		Data: data,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			Audience:  jwt.ClaimStrings{purpose},
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	})
+	return token.SignedString(purposeKey(purpose, secret))
+}
+
+// ValidateSignedToken checks a token made by MakeSignedToken for the same purpose
+// and returns the user and data it was made for.
+func ValidateSignedToken(tokenString, purpose, secret string) (uuid.UUID, string, error) {
+	claims := signedClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
+		return purposeKey(purpose, secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(purpose))
+	if err != nil {
+		return uuid.UUID{}, "", err
+	}
+	user, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.UUID{}, "", err
+	}
+	return user, claims.Data, nil
+}
+
+func purposeKey(purpose, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -12,12 +12,28 @@ import (
 )
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id FROM chirps
+LEFT JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1
+AND (
+    NOT COALESCE(users.is_protected, false)
+    OR users.id = $2
+    OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.followee_id = users.id
+        AND follows.follower_id = $2
+        AND follows.status = 'accepted'
+    )
+)
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
+type GetChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirp(ctx context.Context, arg GetChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, arg.ID, arg.ViewerID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
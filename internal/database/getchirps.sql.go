@@ -7,15 +7,26 @@ package database
 
 import (
 	"context"
+
+	"github.com/google/uuid"
 )
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id FROM chirps
+LEFT JOIN users ON users.id = chirps.user_id
+WHERE NOT COALESCE(users.is_protected, false)
+OR users.id = $1
+OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.followee_id = users.id
+    AND follows.follower_id = $1
+    AND follows.status = 'accepted'
+)
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -12,13 +12,29 @@ import (
 )
 
 const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id FROM chirps
+LEFT JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1
+AND (
+    NOT COALESCE(users.is_protected, false)
+    OR users.id = $2
+    OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.followee_id = users.id
+        AND follows.follower_id = $2
+        AND follows.status = 'accepted'
+    )
+)
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.NullUUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
+type GetChirpsByAuthorParams struct {
+	UserID   uuid.NullUUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsByAuthor(ctx context.Context, arg GetChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
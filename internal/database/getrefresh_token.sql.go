@@ -7,23 +7,83 @@ package database
 
 import (
 	"context"
+
+	"github.com/google/uuid"
 )
 
+const getActiveRefreshTokensForUser = `-- name: GetActiveRefreshTokensForUser :many
+SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at, id, user_agent, ip, last_used_at, family_id, parent_id, rotated_at, access_jti, access_expires_at, client_id, scope FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > NOW()
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetActiveRefreshTokensForUser(ctx context.Context, userID uuid.NullUUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveRefreshTokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.TokenHash,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.ID,
+			&i.UserAgent,
+			&i.Ip,
+			&i.LastUsedAt,
+			&i.FamilyID,
+			&i.ParentID,
+			&i.RotatedAt,
+			&i.AccessJti,
+			&i.AccessExpiresAt,
+			&i.ClientID,
+			&i.Scope,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens
-WHERE token = $1
+SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at, id, user_agent, ip, last_used_at, family_id, parent_id, rotated_at, access_jti, access_expires_at, client_id, scope FROM refresh_tokens
+WHERE token_hash = $1
 `
 
-func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ID,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastUsedAt,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.RotatedAt,
+		&i.AccessJti,
+		&i.AccessExpiresAt,
+		&i.ClientID,
+		&i.Scope,
 	)
 	return i, err
 }
@@ -19,20 +19,215 @@ type Chirp struct {
 	UserID    uuid.NullUUID
 }
 
-type RefreshToken struct {
-	Token     string
+type Conversation struct {
+	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	UserID    uuid.NullUUID
-	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	CreatedBy uuid.NullUUID
 }
 
-type User struct {
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type DataExport struct {
+	ID                uuid.UUID
+	UserID            uuid.UUID
+	CreatedAt         time.Time
+	Status            string
+	FilePath          sql.NullString
+	DownloadTokenHash sql.NullString
+	CompletedAt       sql.NullTime
+	ExpiresAt         sql.NullTime
+}
+
+type DeletedUser struct {
+	ID                  uuid.UUID
+	AccountCreatedAt    time.Time
+	DeletionRequestedAt time.Time
+	DeletedAt           time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Status     string
+}
+
+type List struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	OwnerID   uuid.UUID
+	Name      string
+	IsPrivate bool
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type LoginThrottle struct {
+	Key         string
+	Failures    int32
+	LockedUntil sql.NullTime
+	UpdatedAt   time.Time
+}
+
+type MagicLink struct {
+	TokenHash  string
+	UserID     uuid.UUID
+	DeviceHash string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	UsedAt     sql.NullTime
+}
+
+type Message struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword sql.NullString
-	IsChirpyRed    bool
+	ConversationID uuid.UUID
+	SenderID       uuid.NullUUID
+	Body           string
+}
+
+type Notification struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Kind       string
+	SubjectID  uuid.UUID
+	ActorID    uuid.NullUUID
+	ActorCount int32
+	ReadAt     sql.NullTime
+}
+
+type NotificationActor struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+	FamilyID      uuid.NullUUID
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	RedirectUris string
+	Scopes       string
+	SecretHash   sql.NullString
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Label      string
+	TokenHash  string
+	Scopes     string
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type RecoveryCode struct {
+	CodeHash  string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	TokenHash       string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	UserID          uuid.NullUUID
+	ExpiresAt       time.Time
+	RevokedAt       sql.NullTime
+	ID              uuid.UUID
+	UserAgent       string
+	Ip              string
+	LastUsedAt      sql.NullTime
+	FamilyID        uuid.UUID
+	ParentID        uuid.NullUUID
+	RotatedAt       sql.NullTime
+	AccessJti       uuid.UUID
+	AccessExpiresAt time.Time
+	ClientID        uuid.NullUUID
+	Scope           sql.NullString
+}
+
+type RevokedJti struct {
+	Jti       uuid.UUID
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+type SigningKey struct {
+	ID          string
+	CreatedAt   time.Time
+	Alg         string
+	SealedKey   string
+	Status      string
+	ActivatesAt time.Time
+}
+
+type User struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      sql.NullString
+	IsChirpyRed         bool
+	IsProtected         bool
+	EmailVerifiedAt     sql.NullTime
+	DeletionRequestedAt sql.NullTime
+	TotpSecret          sql.NullString
+	TotpEnabledAt       sql.NullTime
+	TotpLastStep        int64
+	Role                string
+}
+
+type UserIdentity struct {
+	Issuer      string
+	Subject     string
+	UserID      uuid.UUID
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+type UserSuggestion struct {
+	UserID      uuid.UUID
+	SuggestedID uuid.UUID
+	Score       float64
+	ComputedAt  time.Time
 }
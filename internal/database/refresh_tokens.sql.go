@@ -7,40 +7,125 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at,user_id,expires_at,revoked_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at,user_id,expires_at,revoked_at, id, user_agent, ip, last_used_at, family_id, parent_id, access_jti, access_expires_at, client_id, scope)
 VALUES (
     $1,
     NOW(),
     NOW(),
     $2,
     $3,
-    NULL
+    NULL,
+    gen_random_uuid(),
+    $4,
+    $5,
+    NOW(),
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token_hash, created_at, updated_at, user_id, expires_at, revoked_at, id, user_agent, ip, last_used_at, family_id, parent_id, rotated_at, access_jti, access_expires_at, client_id, scope
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string
-	UserID    uuid.NullUUID
-	ExpiresAt time.Time
+	TokenHash       string
+	UserID          uuid.NullUUID
+	ExpiresAt       time.Time
+	UserAgent       string
+	Ip              string
+	FamilyID        uuid.UUID
+	ParentID        uuid.NullUUID
+	AccessJti       uuid.UUID
+	AccessExpiresAt time.Time
+	ClientID        uuid.NullUUID
+	Scope           sql.NullString
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.TokenHash,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.UserAgent,
+		arg.Ip,
+		arg.FamilyID,
+		arg.ParentID,
+		arg.AccessJti,
+		arg.AccessExpiresAt,
+		arg.ClientID,
+		arg.Scope,
+	)
+	var i RefreshToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ID,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastUsedAt,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.RotatedAt,
+		&i.AccessJti,
+		&i.AccessExpiresAt,
+		&i.ClientID,
+		&i.Scope,
+	)
+	return i, err
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	return err
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :one
+UPDATE refresh_tokens
+SET rotated_at = NOW(), last_used_at = NOW(), updated_at = NOW()
+WHERE token_hash = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING token_hash, created_at, updated_at, user_id, expires_at, revoked_at, id, user_agent, ip, last_used_at, family_id, parent_id, rotated_at, access_jti, access_expires_at, client_id, scope
+`
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, rotateRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ID,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastUsedAt,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.RotatedAt,
+		&i.AccessJti,
+		&i.AccessExpiresAt,
+		&i.ClientID,
+		&i.Scope,
 	)
 	return i, err
 }
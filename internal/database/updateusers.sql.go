@@ -12,21 +12,140 @@ import (
 	"github.com/google/uuid"
 )
 
-const updateUser = `-- name: UpdateUser :one
+const patchUser = `-- name: PatchUser :one
 UPDATE users
-SET email = $2 , hashed_password = $3
-WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET email = COALESCE($1, email),
+    hashed_password = COALESCE($2, hashed_password),
+    email_verified_at = CASE WHEN COALESCE($1, email) = email THEN email_verified_at ELSE NULL END,
+    updated_at = NOW()
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at, deletion_requested_at, totp_secret, totp_enabled_at, totp_last_step, role
+`
+
+type PatchUserParams struct {
+	Email          sql.NullString
+	HashedPassword sql.NullString
+	ID             uuid.UUID
+}
+
+func (q *Queries) PatchUser(ctx context.Context, arg PatchUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, patchUser, arg.Email, arg.HashedPassword, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+	)
+	return i, err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $1
+WHERE id = $2 AND hashed_password = $3
 `
 
-type UpdateUserParams struct {
+type RehashUserPasswordParams struct {
+	NewHash        sql.NullString
 	ID             uuid.UUID
-	Email          string
 	HashedPassword sql.NullString
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.ID, arg.Email, arg.HashedPassword)
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewHash, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const setUserProtected = `-- name: SetUserProtected :one
+UPDATE users
+SET is_protected = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at, deletion_requested_at, totp_secret, totp_enabled_at, totp_last_step, role
+`
+
+type SetUserProtectedParams struct {
+	ID          uuid.UUID
+	IsProtected bool
+}
+
+func (q *Queries) SetUserProtected(ctx context.Context, arg SetUserProtectedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserProtected, arg.ID, arg.IsProtected)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at, deletion_requested_at, totp_secret, totp_enabled_at, totp_last_step, role
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+	)
+	return i, err
+}
+
+const setUserRoleByEmail = `-- name: SetUserRoleByEmail :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE email = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at, deletion_requested_at, totp_secret, totp_enabled_at, totp_last_step, role
+`
+
+type SetUserRoleByEmailParams struct {
+	Email string
+	Role  string
+}
+
+func (q *Queries) SetUserRoleByEmail(ctx context.Context, arg SetUserRoleByEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRoleByEmail, arg.Email, arg.Role)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -35,6 +154,48 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
 	)
 	return i, err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword sql.NullString
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2 AND email_verified_at IS NULL
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, verifyUserEmail, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -19,7 +19,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_protected, email_verified_at, deletion_requested_at, totp_secret, totp_enabled_at, totp_last_step, role
 `
 
 type CreateUserParams struct {
@@ -37,6 +37,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsProtected,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
 	)
 	return i, err
 }
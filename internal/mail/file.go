@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer writes every message as a .eml file in dir instead of sending it,
+// so links in outgoing mail can be followed during development.
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	body, err := format(m.from, msg)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(m.dir, 0o700)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o600)
+}
@@ -0,0 +1,258 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/o0n1x/chirpy/internal/auth"
+)
+
+// keys are fetched again for an unknown kid, but not more often than this
+const jwksRefetchInterval = time.Minute
+
+// Metadata is the part of the provider's discovery document Chirpy uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the ID token claims Chirpy reads.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce string `json:"nonce"`
+	Email string `json:"email"`
+	// read with EmailVerified
+	RawEmailVerified flexBool `json:"email_verified"`
+}
+
+// EmailVerified reports whether the provider has verified Email.
+func (c Claims) EmailVerified() bool {
+	return bool(c.RawEmailVerified)
+}
+
+// Provider is an OpenID Connect provider Chirpy is a client of. Discovery
+// happens on first use, so a provider that is down doesn't stop the server starting.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	HTTPClient   *http.Client
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+func NewProvider(issuer, clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL is where to send the user to sign in. state and nonce tie the
+// callback and the ID token to this login; codeChallenge is an S256 PKCE challenge.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", "openid email")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Exchange trades an authorization code for the provider's tokens and
+// returns the raw ID token, which still has to go through VerifyIDToken.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	tokens := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens)
+	if err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+	return tokens.IDToken, nil
+}
+
+// VerifyIDToken checks the ID token's signature against the provider's JWKS,
+// its issuer, audience and expiry, and that it carries the nonce of this login.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	claims := Claims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, metadata.JWKSURI, kid)
+	}, jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt())
+	if err != nil {
+		return Claims{}, err
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return Claims{}, fmt.Errorf("ID token nonce does not match")
+	}
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("ID token has no subject")
+	}
+	return claims, nil
+}
+
+// discover fetches the discovery document once and keeps it.
+func (p *Provider) discover(ctx context.Context) (Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return *p.metadata, nil
+	}
+
+	metadata := Metadata{}
+	err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &metadata)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("discovering %s: %w", p.Issuer, err)
+	}
+	// OpenID Connect Discovery 1.0 section 4.3
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.Issuer {
+		return Metadata{}, fmt.Errorf("discovery document is for issuer %q, not %q", metadata.Issuer, p.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return Metadata{}, fmt.Errorf("discovery document for %s is missing endpoints", p.Issuer)
+	}
+	p.metadata = &metadata
+	return metadata, nil
+}
+
+// key returns the verification key named kid, fetching the JWKS again when the
+// provider has rotated to a key not seen yet.
+func (p *Provider) key(ctx context.Context, jwksURI, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < jwksRefetchInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	set := auth.JWKSet{}
+	err := p.getJSON(ctx, jwksURI, &set)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", err)
+	}
+	keys := map[string]any{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// keys we can't use are skipped rather than failing the whole set
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// flexBool accepts "true" and "false" as strings too, which some providers
+// send for email_verified.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	case "false", `"false"`, "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/o0n1x/chirpy/internal/auth"
+)
+
+// mockIdP is an OpenID Connect provider with one client, serving discovery,
+// its JWKS and a token endpoint. Codes are issued by the test with issueCode
+// rather than through a browser.
+type mockIdP struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	kid    string
+	key    crypto.Signer
+	jwks   auth.JWKSet
+	codes  map[string]mockGrant
+	claims func(g mockGrant) jwt.MapClaims
+}
+
+type mockGrant struct {
+	nonce     string
+	challenge string
+}
+
+const (
+	mockClientID     = "chirpy"
+	mockClientSecret = "s3cret"
+	mockRedirectURL  = "http://localhost:8080/api/login/oidc/callback"
+)
+
+func newMockIdP(t *testing.T) *mockIdP {
+	idp := &mockIdP{codes: map[string]mockGrant{}}
+	idp.server = httptest.NewServer(http.HandlerFunc(idp.serveHTTP))
+	t.Cleanup(idp.server.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	point, err := key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatalf("encoding key: %v", err)
+	}
+	idp.kid = "ec-1"
+	idp.key = key
+	idp.jwks = auth.JWKSet{Keys: []auth.JWK{{
+		Kty: "EC",
+		Use: "sig",
+		Alg: "ES256",
+		Kid: idp.kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(point[1:33]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[33:]),
+	}}}
+	idp.claims = func(g mockGrant) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            idp.server.URL,
+			"sub":            "user-123",
+			"aud":            mockClientID,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          g.nonce,
+			"email":          "jane@example.com",
+			"email_verified": "true",
+		}
+	}
+	return idp
+}
+
+// rotate switches to a new Ed25519 key and drops the old one from the JWKS.
+func (idp *mockIdP) rotate(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	jwk, _ := auth.NewEd25519Key("ed-2", priv).PublicJWK()
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.kid = "ed-2"
+	idp.key = priv
+	idp.jwks = auth.JWKSet{Keys: []auth.JWK{jwk}}
+}
+
+func (idp *mockIdP) issueCode(nonce, challenge string) string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code := rand.Text()
+	idp.codes[code] = mockGrant{nonce: nonce, challenge: challenge}
+	return code
+}
+
+func (idp *mockIdP) sign(claims jwt.MapClaims) string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	method := jwt.SigningMethod(jwt.SigningMethodES256)
+	if _, ok := idp.key.(ed25519.PrivateKey); ok {
+		method = jwt.SigningMethodEdDSA
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = idp.kid
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (idp *mockIdP) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	// answered under any prefix, so a client configured with the wrong issuer still finds it
+	case strings.HasSuffix(r.URL.Path, "/.well-known/openid-configuration"):
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	case r.URL.Path == "/jwks":
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode(idp.jwks)
+	case r.URL.Path == "/token":
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != mockClientID || secret != mockClientSecret {
+			w.WriteHeader(401)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		idp.mu.Lock()
+		grant, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.mu.Unlock()
+		if !ok || r.PostFormValue("redirect_uri") != mockRedirectURL ||
+			!auth.VerifyPKCE(r.PostFormValue("code_verifier"), grant.challenge) {
+			w.WriteHeader(400)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "unused",
+			"token_type":   "Bearer",
+			"id_token":     idp.sign(idp.claims(grant)),
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := NewProvider(idp.server.URL, mockClientID, mockClientSecret, mockRedirectURL)
+	ctx := context.Background()
+
+	verifier := rand.Text() + rand.Text()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", auth.PKCEChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", authURL, err)
+	}
+	query := u.Query()
+	if u.Path != "/authorize" || query.Get("state") != "state-1" || query.Get("client_id") != mockClientID ||
+		query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization URL %q", authURL)
+	}
+
+	code := idp.issueCode(query.Get("nonce"), query.Get("code_challenge"))
+	if _, err := provider.Exchange(ctx, code, "wrong-"+verifier); err == nil {
+		t.Fatal("expected exchange with the wrong verifier to fail")
+	}
+	code = idp.issueCode(query.Get("nonce"), query.Get("code_challenge"))
+	idToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, idToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "jane@example.com" || !claims.EmailVerified() {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	valid := func() jwt.MapClaims { return idp.claims(mockGrant{nonce: "nonce-1"}) }
+
+	cases := map[string]struct {
+		claims  func() jwt.MapClaims
+		nonce   string
+		wantErr bool
+	}{
+		"valid":        {valid, "nonce-1", false},
+		"wrong nonce":  {valid, "nonce-2", true},
+		"no nonce":     {valid, "", true},
+		"wrong issuer": {func() jwt.MapClaims { c := valid(); c["iss"] = "https://evil.example"; return c }, "nonce-1", true},
+		"wrong aud":    {func() jwt.MapClaims { c := valid(); c["aud"] = "someone-else"; return c }, "nonce-1", true},
+		"expired":      {func() jwt.MapClaims { c := valid(); c["exp"] = time.Now().Add(-time.Minute).Unix(); return c }, "nonce-1", true},
+		"no expiry":    {func() jwt.MapClaims { c := valid(); delete(c, "exp"); return c }, "nonce-1", true},
+		"no subject":   {func() jwt.MapClaims { c := valid(); delete(c, "sub"); return c }, "nonce-1", true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			provider := NewProvider(idp.server.URL, mockClientID, mockClientSecret, mockRedirectURL)
+			_, err := provider.VerifyIDToken(context.Background(), idp.sign(tc.claims()), tc.nonce)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsForeignKey(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := NewProvider(idp.server.URL, mockClientID, mockClientSecret, mockRedirectURL)
+
+	// same kid, but a key the provider never published
+	other := newMockIdP(t)
+	other.kid = idp.kid
+	claims := idp.claims(mockGrant{nonce: "nonce-1"})
+	_, err := provider.VerifyIDToken(context.Background(), other.sign(claims), "nonce-1")
+	if err == nil {
+		t.Error("expected a token signed with an unpublished key to fail")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := NewProvider(idp.server.URL, mockClientID, mockClientSecret, mockRedirectURL)
+	ctx := context.Background()
+	claims := idp.claims(mockGrant{nonce: "nonce-1"})
+
+	_, err := provider.VerifyIDToken(ctx, idp.sign(claims), "nonce-1")
+	if err != nil {
+		t.Fatalf("before rotation: %v", err)
+	}
+
+	idp.rotate(t)
+	rotated := idp.sign(claims)
+	_, err = provider.VerifyIDToken(ctx, rotated, "nonce-1")
+	if err == nil {
+		t.Fatal("expected the JWKS not to be refetched within the refetch interval")
+	}
+
+	provider.keysFetchedAt = time.Now().Add(-jwksRefetchInterval)
+	_, err = provider.VerifyIDToken(ctx, rotated, "nonce-1")
+	if err != nil {
+		t.Fatalf("after rotation: %v", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	// the same server reached under another name must not be trusted as that issuer
+	provider := NewProvider(idp.server.URL+"/other", mockClientID, mockClientSecret, mockRedirectURL)
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err == nil {
+		t.Error("expected discovery to fail for a mismatched issuer")
+	}
+}
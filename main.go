@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/o0n1x/chirpy/internal/api"
+	"github.com/o0n1x/chirpy/internal/auth"
 	"github.com/o0n1x/chirpy/internal/database"
+	"github.com/o0n1x/chirpy/internal/mail"
+	"github.com/o0n1x/chirpy/internal/oidc"
 )
 
 func main() {
@@ -26,25 +33,213 @@ func main() {
 	}
 	cfg := api.ApiConfig{FileserverHits: atomic.Int32{}}
 	cfg.DB = database.New(db)
+	cfg.DBConn = db
 	cfg.Platform = os.Getenv("PLATFORM")
 	cfg.SECRET_JWT = secret
+	cfg.KeyEncryptionKey = os.Getenv("SIGNING_KEY_ENCRYPTION_KEY")
+	if cfg.KeyEncryptionKey == "" {
+		// how keys were sealed before SIGNING_KEY_ENCRYPTION_KEY existed
+		cfg.KeyEncryptionKey = secret
+	}
+	// the environment key seeds the signing_keys table on first start; after that
+	// keys are managed with `chirpy keys` and reloaded from the database
+	signingKey := auth.NewHMACKey("default", secret)
+	if keyFile := os.Getenv("JWT_SIGNING_KEY_FILE"); keyFile != "" {
+		pemBytes, err := os.ReadFile(keyFile)
+		if err != nil {
+			log.Fatalf("Error reading JWT_SIGNING_KEY_FILE: %v", err)
+		}
+		signingKey, err = auth.ParsePrivateKeyPEM(os.Getenv("JWT_KEY_ID"), pemBytes)
+		if err != nil {
+			log.Fatalf("Error parsing JWT_SIGNING_KEY_FILE: %v", err)
+		}
+	}
+	cfg.Keyring = auth.NewKeyring(signingKey)
+	err = cfg.SeedSigningKey(context.Background(), signingKey)
+	if err != nil {
+		log.Fatalf("Error seeding signing keys: %v", err)
+	}
+	err = cfg.ReloadKeyring(context.Background())
+	if err != nil {
+		log.Fatalf("Error loading signing keys: %v", err)
+	}
 	cfg.PolkaKey = polkakey
+	cfg.BaseURL = os.Getenv("BASE_URL")
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:" + port
+	}
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "Chirpy <no-reply@localhost>"
+	}
+	if os.Getenv("MAIL_BACKEND") == "smtp" {
+		cfg.Mailer = mail.NewSMTPMailer(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), mailFrom)
+	} else {
+		mailDir := os.Getenv("MAIL_DIR")
+		if mailDir == "" {
+			mailDir = "mail"
+		}
+		cfg.Mailer = mail.NewFileMailer(mailDir, mailFrom)
+	}
+	cfg.DeletionGracePeriod = 30 * 24 * time.Hour
+	if grace := os.Getenv("ACCOUNT_DELETION_GRACE"); grace != "" {
+		cfg.DeletionGracePeriod, err = time.ParseDuration(grace)
+		if err != nil {
+			log.Fatalf("Error parsing ACCOUNT_DELETION_GRACE: %v", err)
+		}
+	}
+	cfg.ExportDir = os.Getenv("EXPORT_DIR")
+	if cfg.ExportDir == "" {
+		cfg.ExportDir = "exports"
+	}
+	cfg.PasswordPolicy = auth.DefaultPasswordPolicy()
+	if minLength := os.Getenv("PASSWORD_MIN_LENGTH"); minLength != "" {
+		cfg.PasswordPolicy.MinLength, err = strconv.Atoi(minLength)
+		if err != nil {
+			log.Fatalf("Error parsing PASSWORD_MIN_LENGTH: %v", err)
+		}
+	}
+	if minEntropy := os.Getenv("PASSWORD_MIN_ENTROPY"); minEntropy != "" {
+		cfg.PasswordPolicy.MinEntropy, err = strconv.ParseFloat(minEntropy, 64)
+		if err != nil {
+			log.Fatalf("Error parsing PASSWORD_MIN_ENTROPY: %v", err)
+		}
+	}
+	if bannedFile := os.Getenv("PASSWORD_BANNED_FILE"); bannedFile != "" {
+		err = cfg.PasswordPolicy.LoadBannedPasswords(bannedFile)
+		if err != nil {
+			log.Fatalf("Error loading PASSWORD_BANNED_FILE: %v", err)
+		}
+	}
+	cfg.PasswordHashParams = auth.DefaultHashParams()
+	if memory := os.Getenv("PASSWORD_HASH_MEMORY"); memory != "" {
+		n, err := strconv.ParseUint(memory, 10, 32)
+		if err != nil {
+			log.Fatalf("Error parsing PASSWORD_HASH_MEMORY: %v", err)
+		}
+		cfg.PasswordHashParams.Memory = uint32(n)
+	}
+	if iterations := os.Getenv("PASSWORD_HASH_ITERATIONS"); iterations != "" {
+		n, err := strconv.ParseUint(iterations, 10, 32)
+		if err != nil {
+			log.Fatalf("Error parsing PASSWORD_HASH_ITERATIONS: %v", err)
+		}
+		cfg.PasswordHashParams.Iterations = uint32(n)
+	}
+	if parallelism := os.Getenv("PASSWORD_HASH_PARALLELISM"); parallelism != "" {
+		n, err := strconv.ParseUint(parallelism, 10, 8)
+		if err != nil {
+			log.Fatalf("Error parsing PASSWORD_HASH_PARALLELISM: %v", err)
+		}
+		cfg.PasswordHashParams.Parallelism = uint8(n)
+	}
+	err = cfg.PasswordHashParams.Validate()
+	if err != nil {
+		log.Fatalf("Invalid password hash parameters: %v", err)
+	}
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		cfg.OIDC = oidc.NewProvider(issuer, os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"), cfg.BaseURL+"/api/login/oidc/callback")
+	}
+	cfg.VerifiedEmailRequired = map[string]bool{}
+	for _, action := range strings.Split(os.Getenv("REQUIRE_VERIFIED_EMAIL"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			cfg.VerifiedEmailRequired[action] = true
+		}
+	}
+
+	if len(os.Args) > 1 {
+		err = runCommand(&cfg, os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg.Notifier = api.NewNotifier(cfg.DBConn, 256)
+	go cfg.Notifier.Run(context.Background())
+	go cfg.RunSuggestionsWorker(context.Background(), 15*time.Minute)
+	go cfg.RunAccountDeletionWorker(context.Background(), time.Hour)
+	go cfg.RunExportCleanupWorker(context.Background(), time.Hour)
+	go cfg.RunLoginThrottleCleanupWorker(context.Background(), time.Hour)
+	go cfg.RunKeyringReloader(context.Background(), time.Minute)
+	go cfg.RunDenylistSyncer(context.Background(), 10*time.Second)
+	go cfg.RunOAuthCleanupWorker(context.Background(), time.Hour)
 
 	mux := http.NewServeMux()
 	mux.Handle(filepathRoot, http.StripPrefix("/app/", cfg.MiddlewareMetricsInc(http.FileServer(http.Dir(".")))))
 	mux.HandleFunc("GET /api/healthz", api.Healthz)
-	mux.HandleFunc("GET /admin/metrics", cfg.Gethits)
-	mux.HandleFunc("POST /admin/reset", cfg.Resethits)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.JWKS)
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", cfg.OAuthMetadata)
+	mux.HandleFunc("GET /oauth/authorize", cfg.Authorize)
+	mux.HandleFunc("POST /oauth/authorize", cfg.Approve)
+	mux.HandleFunc("POST /oauth/token", cfg.OAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", cfg.RevokeOAuthToken)
+	mux.HandleFunc("POST /api/oauth/clients", cfg.CreateOAuthClient)
+	mux.HandleFunc("GET /api/oauth/clients", cfg.GetOAuthClients)
+	mux.HandleFunc("DELETE /api/oauth/clients/{id}", cfg.DeleteOAuthClient)
+	// every /admin route checks the caller's role
+	mux.Handle("GET /admin/metrics", cfg.RequireRole(auth.RoleModerator, http.HandlerFunc(cfg.Gethits)))
+	mux.Handle("POST /admin/reset", cfg.RequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.Resethits)))
+	mux.Handle("POST /admin/users/{id}/unlock", cfg.RequireRole(auth.RoleModerator, http.HandlerFunc(cfg.UnlockAccount)))
+	mux.Handle("PUT /admin/users/{id}/role", cfg.RequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.SetUserRole)))
 	mux.HandleFunc("POST /api/chirps", cfg.CreateChirp)
 	mux.HandleFunc("GET /api/chirps", cfg.GetChirps)
 	mux.HandleFunc("GET /api/chirps/{id}", cfg.GetChirps)
 	mux.HandleFunc("DELETE /api/chirps/{id}", cfg.DeleteChirp)
 	mux.HandleFunc("POST /api/users", cfg.CreateUser)
 	mux.HandleFunc("PUT /api/users", cfg.UpdateUser)
+	mux.HandleFunc("PATCH /api/users/me", cfg.PatchUser)
+	mux.HandleFunc("DELETE /api/users/me", cfg.DeleteAccount)
+	mux.HandleFunc("POST /api/users/me/export", cfg.CreateDataExport)
+	mux.HandleFunc("GET /api/users/me/exports/{id}", cfg.GetDataExport)
+	mux.HandleFunc("GET /api/exports/download", cfg.DownloadDataExport)
+	mux.HandleFunc("POST /api/users/me/tokens", cfg.CreatePersonalAccessToken)
+	mux.HandleFunc("GET /api/users/me/tokens", cfg.GetPersonalAccessTokens)
+	mux.HandleFunc("DELETE /api/users/me/tokens/{id}", cfg.RevokePersonalAccessToken)
+	mux.HandleFunc("POST /api/users/me/2fa", cfg.EnrollTOTP)
+	mux.HandleFunc("POST /api/users/me/2fa/confirm", cfg.ConfirmTOTP)
+	mux.HandleFunc("DELETE /api/users/me/2fa", cfg.DisableTOTP)
+	mux.HandleFunc("PUT /api/users/protected", cfg.SetProtected)
+	mux.HandleFunc("GET /api/users/verify", cfg.VerifyEmail)
+	mux.HandleFunc("POST /api/users/verify/resend", cfg.ResendVerification)
+	mux.HandleFunc("GET /api/users/suggestions", cfg.GetSuggestions)
+	mux.HandleFunc("POST /api/users/{id}/follow", cfg.FollowUser)
+	mux.HandleFunc("DELETE /api/users/{id}/follow", cfg.UnfollowUser)
+	mux.HandleFunc("GET /api/follow_requests", cfg.GetFollowRequests)
+	mux.HandleFunc("POST /api/follow_requests/{follower_id}/approve", cfg.ApproveFollowRequest)
+	mux.HandleFunc("POST /api/follow_requests/{follower_id}/reject", cfg.RejectFollowRequest)
 	mux.HandleFunc("POST /api/login", cfg.Login)
+	mux.HandleFunc("POST /api/login/2fa", cfg.LoginTwoFactor)
+	mux.HandleFunc("POST /api/login/magic", cfg.RequestMagicLink)
+	mux.HandleFunc("GET /api/login/magic/verify", cfg.RedeemMagicLink)
+	if cfg.OIDC != nil {
+		mux.HandleFunc("GET /api/login/oidc", cfg.StartOIDCLogin)
+		mux.HandleFunc("GET /api/login/oidc/callback", cfg.OIDCCallback)
+	}
+	mux.HandleFunc("POST /api/password/forgot", cfg.ForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", cfg.ResetPassword)
 	mux.HandleFunc("POST /api/refresh", cfg.Refresh)
 	mux.HandleFunc("POST /api/revoke", cfg.Revoke)
+	mux.HandleFunc("GET /api/sessions", cfg.GetSessions)
+	mux.HandleFunc("DELETE /api/sessions/{id}", cfg.RevokeSession)
+	mux.HandleFunc("POST /api/sessions/revoke_others", cfg.RevokeOtherSessions)
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.PolkaWebhook)
+	mux.HandleFunc("GET /api/notifications", cfg.GetNotifications)
+	mux.HandleFunc("POST /api/notifications/read", cfg.MarkAllNotificationsRead)
+	mux.HandleFunc("POST /api/notifications/{id}/read", cfg.MarkNotificationRead)
+	mux.HandleFunc("POST /api/conversations", cfg.CreateConversation)
+	mux.HandleFunc("GET /api/conversations", cfg.GetConversations)
+	mux.HandleFunc("GET /api/conversations/{id}/messages", cfg.GetMessages)
+	mux.HandleFunc("POST /api/conversations/{id}/messages", cfg.CreateMessage)
+	mux.HandleFunc("POST /api/conversations/{id}/read", cfg.MarkConversationRead)
+	mux.HandleFunc("POST /api/lists", cfg.CreateList)
+	mux.HandleFunc("GET /api/lists", cfg.GetLists)
+	mux.HandleFunc("GET /api/lists/{id}", cfg.GetList)
+	mux.HandleFunc("PUT /api/lists/{id}", cfg.UpdateList)
+	mux.HandleFunc("DELETE /api/lists/{id}", cfg.DeleteList)
+	mux.HandleFunc("POST /api/lists/{id}/members", cfg.AddListMember)
+	mux.HandleFunc("DELETE /api/lists/{id}/members/{user_id}", cfg.RemoveListMember)
+	mux.HandleFunc("GET /api/lists/{id}/chirps", cfg.GetListChirps)
 
 	s := &http.Server{
 		Handler: mux,
@@ -0,0 +1,28 @@
+-- name: ScheduleUserDeletion :one
+UPDATE users
+SET deletion_requested_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CancelUserDeletion :execrows
+UPDATE users
+SET deletion_requested_at = NULL, updated_at = NOW()
+WHERE id = $1 AND deletion_requested_at IS NOT NULL;
+
+-- name: GetUsersDueForDeletion :many
+SELECT * FROM users
+WHERE deletion_requested_at <= sqlc.arg(cutoff)::timestamp;
+
+-- name: CreateDeletedUser :exec
+INSERT INTO deleted_users (id, account_created_at, deletion_requested_at, deleted_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+);
+
+-- name: PurgeUserDueForDeletion :execrows
+-- only if the deletion is still pending, so a login that cancelled it wins
+DELETE FROM users
+WHERE id = sqlc.arg(id) AND deletion_requested_at <= sqlc.arg(cutoff)::timestamp;
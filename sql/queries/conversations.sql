@@ -0,0 +1,53 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at, last_read_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NULL
+);
+
+-- name: GetConversationsForUser :many
+SELECT conversations.* FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = $1
+ORDER BY conversations.updated_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: FindDirectConversation :one
+SELECT c.* FROM conversations c
+WHERE (SELECT COUNT(*) FROM conversation_members m WHERE m.conversation_id = c.id) = 2
+AND EXISTS (SELECT 1 FROM conversation_members m WHERE m.conversation_id = c.id AND m.user_id = sqlc.arg(user_a))
+AND EXISTS (SELECT 1 FROM conversation_members m WHERE m.conversation_id = c.id AND m.user_id = sqlc.arg(user_b))
+LIMIT 1;
+
+-- name: GetConversationMembers :many
+SELECT * FROM conversation_members
+WHERE conversation_id = $1
+ORDER BY joined_at ASC;
+
+-- name: IsConversationMember :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members
+    WHERE conversation_id = $1 AND user_id = $2
+);
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
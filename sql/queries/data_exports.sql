@@ -0,0 +1,41 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, user_id, created_at, status)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW(),
+    'pending'
+)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready', file_path = $2, download_token_hash = $3, completed_at = NOW(), expires_at = $4
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', completed_at = NOW()
+WHERE id = $1;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetDataExportByToken :one
+SELECT * FROM data_exports
+WHERE download_token_hash = $1 AND status = 'ready' AND expires_at > NOW();
+
+-- name: GetDataExportsForUser :many
+SELECT * FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetExpiredDataExports :many
+SELECT * FROM data_exports
+WHERE status = 'ready' AND expires_at <= NOW();
+
+-- name: ExpireDataExport :exec
+UPDATE data_exports
+SET status = 'expired', file_path = NULL, download_token_hash = NULL
+WHERE id = $1;
@@ -1,2 +1,6 @@
 -- name: DeleteUsers :exec
-DELETE FROM users;
\ No newline at end of file
+DELETE FROM users;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1;
\ No newline at end of file
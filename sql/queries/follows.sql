@@ -0,0 +1,34 @@
+-- name: CreateFollow :one
+INSERT INTO follows (follower_id, followee_id, created_at, updated_at, status)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW(),
+    $3
+)
+ON CONFLICT (follower_id, followee_id) DO UPDATE SET updated_at = follows.updated_at
+RETURNING *;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowRequests :many
+SELECT * FROM follows
+WHERE followee_id = $1 AND status = 'pending'
+ORDER BY created_at ASC;
+
+-- name: AcceptFollowRequest :execrows
+UPDATE follows
+SET status = 'accepted', updated_at = NOW()
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: RejectFollowRequest :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: AcceptAllFollowRequests :exec
+UPDATE follows
+SET status = 'accepted', updated_at = NOW()
+WHERE followee_id = $1 AND status = 'pending';
@@ -1,3 +1,14 @@
 -- name: GetChirp :one
-SELECT * FROM chirps
-WHERE id = $1;
\ No newline at end of file
+SELECT chirps.* FROM chirps
+LEFT JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = sqlc.arg(id)
+AND (
+    NOT COALESCE(users.is_protected, false)
+    OR users.id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.followee_id = users.id
+        AND follows.follower_id = sqlc.arg(viewer_id)
+        AND follows.status = 'accepted'
+    )
+);
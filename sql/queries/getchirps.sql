@@ -1,3 +1,12 @@
 -- name: GetChirps :many
-SELECT * FROM chirps
-ORDER BY created_at ASC;
\ No newline at end of file
+SELECT chirps.* FROM chirps
+LEFT JOIN users ON users.id = chirps.user_id
+WHERE NOT COALESCE(users.is_protected, false)
+OR users.id = sqlc.arg(viewer_id)
+OR EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.followee_id = users.id
+    AND follows.follower_id = sqlc.arg(viewer_id)
+    AND follows.status = 'accepted'
+)
+ORDER BY chirps.created_at ASC;
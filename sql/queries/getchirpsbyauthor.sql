@@ -1,4 +1,15 @@
 -- name: GetChirpsByAuthor :many
-SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
\ No newline at end of file
+SELECT chirps.* FROM chirps
+LEFT JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = sqlc.arg(user_id)
+AND (
+    NOT COALESCE(users.is_protected, false)
+    OR users.id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.followee_id = users.id
+        AND follows.follower_id = sqlc.arg(viewer_id)
+        AND follows.status = 'accepted'
+    )
+)
+ORDER BY chirps.created_at ASC;
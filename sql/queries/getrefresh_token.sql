@@ -1,3 +1,8 @@
 -- name: GetRefreshToken :one
 SELECT * FROM refresh_tokens
-WHERE token = $1;
\ No newline at end of file
+WHERE token_hash = $1;
+
+-- name: GetActiveRefreshTokensForUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > NOW()
+ORDER BY created_at ASC;
\ No newline at end of file
@@ -0,0 +1,66 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, is_private)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetList :one
+SELECT * FROM lists
+WHERE id = $1;
+
+-- name: GetListsByOwner :many
+SELECT * FROM lists
+WHERE owner_id = $1
+ORDER BY created_at ASC;
+
+-- name: UpdateList :one
+UPDATE lists
+SET name = $2, is_private = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteList :exec
+DELETE FROM lists
+WHERE id = $1;
+
+-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: GetListMembers :many
+SELECT * FROM list_members
+WHERE list_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetListChirps :many
+SELECT chirps.* FROM chirps
+JOIN list_members ON list_members.user_id = chirps.user_id
+JOIN users ON users.id = chirps.user_id
+WHERE list_members.list_id = sqlc.arg(list_id)
+AND (
+    NOT users.is_protected
+    OR users.id = sqlc.arg(viewer_id)
+    OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.followee_id = users.id
+        AND follows.follower_id = sqlc.arg(viewer_id)
+        AND follows.status = 'accepted'
+    )
+)
+ORDER BY chirps.created_at DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
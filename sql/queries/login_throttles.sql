@@ -0,0 +1,33 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE key = $1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, failures, locked_until, updated_at)
+VALUES (
+    $1,
+    1,
+    NULL,
+    NOW()
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.updated_at < NOW() - INTERVAL '24 hours' THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE key = $1;
+
+-- name: DeleteLoginThrottle :execrows
+DELETE FROM login_throttles
+WHERE key = $1;
+
+-- name: DeleteStaleLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE updated_at < NOW() - INTERVAL '24 hours'
+AND (locked_until IS NULL OR locked_until < NOW());
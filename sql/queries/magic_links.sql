@@ -0,0 +1,19 @@
+-- name: CreateMagicLink :exec
+INSERT INTO magic_links (token_hash, user_id, device_hash, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW(),
+    $4
+);
+
+-- name: DeleteMagicLinks :exec
+DELETE FROM magic_links
+WHERE user_id = $1;
+
+-- name: UseMagicLink :one
+UPDATE magic_links
+SET used_at = NOW()
+WHERE token_hash = $1 AND device_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+RETURNING user_id;
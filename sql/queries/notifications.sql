@@ -0,0 +1,50 @@
+-- name: UpsertNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, kind, subject_id, actor_id, actor_count, read_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    -- AddNotificationActor counts actors; system events have none and count as one
+    CASE WHEN $4::uuid IS NULL THEN 1 ELSE 0 END,
+    NULL
+)
+ON CONFLICT (user_id, kind, subject_id) WHERE read_at IS NULL
+DO UPDATE SET
+    updated_at = NOW(),
+    actor_id = EXCLUDED.actor_id
+RETURNING id;
+
+-- name: AddNotificationActor :exec
+WITH added AS (
+    INSERT INTO notification_actors (notification_id, actor_id)
+    VALUES ($1, $2)
+    ON CONFLICT DO NOTHING
+    RETURNING notification_id
+)
+UPDATE notifications
+SET actor_count = actor_count + 1
+WHERE id IN (SELECT notification_id FROM added);
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY updated_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
@@ -0,0 +1,53 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, owner_id, name, redirect_uris, scopes, secret_hash)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: GetOAuthClientsForOwner :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1 AND owner_id = $2;
+
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scope, code_challenge, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW(),
+    $7
+);
+
+-- name: UseAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW(), family_id = $2
+WHERE code_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: GetUsedAuthorizationCode :one
+SELECT * FROM oauth_authorization_codes
+WHERE code_hash = $1 AND used_at IS NOT NULL;
+
+-- name: DeleteExpiredAuthorizationCodes :exec
+-- used codes are kept a day longer so a late reuse is still caught
+DELETE FROM oauth_authorization_codes
+WHERE expires_at < NOW() AND (used_at IS NULL OR used_at < NOW() - INTERVAL '1 day');
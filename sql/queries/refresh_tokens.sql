@@ -1,11 +1,32 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at,user_id,expires_at,revoked_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at,user_id,expires_at,revoked_at, id, user_agent, ip, last_used_at, family_id, parent_id, access_jti, access_expires_at, client_id, scope)
 VALUES (
     $1,
     NOW(),
     NOW(),
     $2,
     $3,
-    NULL
+    NULL,
+    gen_random_uuid(),
+    $4,
+    $5,
+    NOW(),
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
 )
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: RotateRefreshToken :one
+UPDATE refresh_tokens
+SET rotated_at = NOW(), last_used_at = NOW(), updated_at = NOW()
+WHERE token_hash = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
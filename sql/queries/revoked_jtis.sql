@@ -0,0 +1,34 @@
+-- name: RevokeJTI :exec
+INSERT INTO revoked_jtis (jti, expires_at, revoked_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeUserAccessTokens :many
+INSERT INTO revoked_jtis (jti, expires_at, revoked_at)
+SELECT access_jti, access_expires_at, NOW() FROM refresh_tokens
+WHERE user_id = $1 AND access_expires_at > NOW()
+ON CONFLICT (jti) DO NOTHING
+RETURNING *;
+
+-- name: RevokeFamilyAccessTokens :many
+INSERT INTO revoked_jtis (jti, expires_at, revoked_at)
+SELECT access_jti, access_expires_at, NOW() FROM refresh_tokens
+WHERE family_id = $1 AND access_expires_at > NOW()
+ON CONFLICT (jti) DO NOTHING
+RETURNING *;
+
+-- name: GetRevokedJTIsSince :many
+SELECT * FROM revoked_jtis
+WHERE revoked_at > $1 AND expires_at > NOW()
+ORDER BY revoked_at ASC;
+
+-- name: DeleteExpiredRevokedJTIs :exec
+DELETE FROM revoked_jtis
+WHERE expires_at < NOW();
+
+-- name: RevokeClientAccessTokens :many
+INSERT INTO revoked_jtis (jti, expires_at, revoked_at)
+SELECT access_jti, access_expires_at, NOW() FROM refresh_tokens
+WHERE client_id = $1 AND access_expires_at > NOW()
+ON CONFLICT (jti) DO NOTHING
+RETURNING *;
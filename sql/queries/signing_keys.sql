@@ -0,0 +1,25 @@
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys (id, created_at, alg, sealed_key, status, activates_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetSigningKeys :many
+SELECT * FROM signing_keys
+ORDER BY activates_at ASC;
+
+-- name: SetSigningKeyStatus :execrows
+UPDATE signing_keys
+SET status = $2
+WHERE id = $1;
+
+-- name: SetSigningKeySealedKey :exec
+UPDATE signing_keys
+SET sealed_key = $2
+WHERE id = $1;
@@ -0,0 +1,36 @@
+-- name: SetPendingTOTPSecret :execrows
+UPDATE users
+SET totp_secret = $2, updated_at = NOW()
+WHERE id = $1 AND totp_enabled_at IS NULL;
+
+-- name: EnableTOTP :execrows
+UPDATE users
+SET totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW()
+WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND totp_last_step < $2;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (code_hash, user_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE code_hash = $1 AND user_id = $2 AND used_at IS NULL;
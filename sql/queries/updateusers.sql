@@ -1,5 +1,41 @@
--- name: UpdateUser :one
+-- name: SetUserProtected :one
 UPDATE users
-SET email = $2 , hashed_password = $3
+SET is_protected = $2, updated_at = NOW()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2 AND email_verified_at IS NULL;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: PatchUser :one
+UPDATE users
+SET email = COALESCE(sqlc.narg(email), email),
+    hashed_password = COALESCE(sqlc.narg(hashed_password), hashed_password),
+    email_verified_at = CASE WHEN COALESCE(sqlc.narg(email), email) = email THEN email_verified_at ELSE NULL END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserRoleByEmail :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE email = $1
+RETURNING *;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = sqlc.arg(new_hash)
+WHERE id = sqlc.arg(id) AND hashed_password = sqlc.arg(hashed_password);
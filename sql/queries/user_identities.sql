@@ -0,0 +1,20 @@
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (issuer, subject, user_id, email, created_at, last_login_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET last_login_at = NOW(), email = $3
+WHERE issuer = $1 AND subject = $2;
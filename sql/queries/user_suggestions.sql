@@ -0,0 +1,79 @@
+-- name: DeleteUserSuggestions :exec
+DELETE FROM user_suggestions;
+
+-- name: ComputeUserSuggestions :exec
+WITH friends_of_friends AS (
+    SELECT f1.follower_id AS user_id, f2.followee_id AS suggested_id, COUNT(DISTINCT f1.followee_id) AS mutuals
+    FROM follows f1
+    JOIN follows f2 ON f2.follower_id = f1.followee_id
+    WHERE f1.status = 'accepted' AND f2.status = 'accepted'
+    GROUP BY f1.follower_id, f2.followee_id
+),
+-- protected accounts' chirps are left out of both signals, since the ranking
+-- would otherwise tell non-followers something about them
+hashtags AS (
+    SELECT DISTINCT chirps.user_id, LOWER(tag[1]) AS tag
+    FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    CROSS JOIN regexp_matches(chirps.body, '#(\w+)', 'g') AS tag
+    WHERE NOT users.is_protected
+),
+shared_hashtags AS (
+    SELECT a.user_id, b.user_id AS suggested_id, COUNT(*) AS shared
+    FROM hashtags a
+    JOIN hashtags b ON b.tag = a.tag AND b.user_id <> a.user_id
+    GROUP BY a.user_id, b.user_id
+),
+recent_activity AS (
+    SELECT chirps.user_id, COUNT(*) AS recent
+    FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE chirps.created_at > NOW() - INTERVAL '7 days' AND NOT users.is_protected
+    GROUP BY chirps.user_id
+),
+most_active AS (
+    SELECT recent_activity.user_id FROM recent_activity
+    ORDER BY recent_activity.recent DESC
+    LIMIT 20
+),
+candidates AS (
+    SELECT friends_of_friends.user_id, friends_of_friends.suggested_id FROM friends_of_friends
+    UNION
+    SELECT shared_hashtags.user_id, shared_hashtags.suggested_id FROM shared_hashtags
+    UNION
+    SELECT users.id, most_active.user_id FROM users CROSS JOIN most_active
+)
+INSERT INTO user_suggestions (user_id, suggested_id, score, computed_at)
+SELECT
+    candidates.user_id,
+    candidates.suggested_id,
+    (
+        3 * COALESCE(friends_of_friends.mutuals, 0)
+        + 2 * COALESCE(shared_hashtags.shared, 0)
+        + LN(1 + COALESCE(recent_activity.recent, 0))
+    )::DOUBLE PRECISION,
+    NOW()
+FROM candidates
+LEFT JOIN friends_of_friends
+    ON friends_of_friends.user_id = candidates.user_id AND friends_of_friends.suggested_id = candidates.suggested_id
+LEFT JOIN shared_hashtags
+    ON shared_hashtags.user_id = candidates.user_id AND shared_hashtags.suggested_id = candidates.suggested_id
+LEFT JOIN recent_activity
+    ON recent_activity.user_id = candidates.suggested_id
+JOIN users suggested ON suggested.id = candidates.suggested_id
+WHERE candidates.user_id <> candidates.suggested_id
+AND suggested.deletion_requested_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = candidates.user_id AND follows.followee_id = candidates.suggested_id
+);
+
+-- name: GetUserSuggestions :many
+SELECT user_suggestions.suggested_id FROM user_suggestions
+WHERE user_suggestions.user_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = user_suggestions.user_id AND follows.followee_id = user_suggestions.suggested_id
+)
+ORDER BY user_suggestions.score DESC
+LIMIT $2 OFFSET $3;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    kind TEXT NOT NULL,
+    subject_id UUID NOT NULL,
+    actor_id UUID,
+    actor_count INTEGER NOT NULL DEFAULT 1,
+    read_at TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(actor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- unread notifications about the same thing are grouped into one row
+CREATE UNIQUE INDEX notifications_unread_group
+ON notifications (user_id, kind, subject_id)
+WHERE read_at IS NULL;
+
+-- +goose Down
+DROP TABLE notifications;
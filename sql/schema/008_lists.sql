@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE lists (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    owner_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT false,
+    FOREIGN KEY(owner_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE list_members (
+    list_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(list_id, user_id),
+    FOREIGN KEY(list_id) REFERENCES lists(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
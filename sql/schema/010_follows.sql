@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    -- 'pending' until a protected account approves the request, then 'accepted'
+    status TEXT NOT NULL,
+    PRIMARY KEY(follower_id, followee_id),
+    FOREIGN KEY(follower_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(followee_id) REFERENCES users(id) ON DELETE CASCADE,
+    CHECK (follower_id <> followee_id),
+    CHECK (status IN ('pending', 'accepted'))
+);
+
+CREATE INDEX follows_followee_id ON follows (followee_id, status);
+
+-- +goose Down
+DROP TABLE follows;
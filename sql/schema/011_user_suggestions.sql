@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE user_suggestions (
+    user_id UUID NOT NULL,
+    suggested_id UUID NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY(user_id, suggested_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(suggested_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE user_suggestions;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN deletion_requested_at TIMESTAMP;
+
+-- anonymized record of purged accounts, kept for audit
+CREATE TABLE deleted_users (
+    id UUID PRIMARY KEY,
+    account_created_at TIMESTAMP NOT NULL,
+    deletion_requested_at TIMESTAMP NOT NULL,
+    deleted_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE deleted_users;
+
+ALTER TABLE users
+DROP COLUMN deletion_requested_at;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE data_exports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    -- 'pending' while the archive is built, then 'ready', 'failed' or 'expired'
+    status TEXT NOT NULL,
+    file_path TEXT,
+    download_token_hash TEXT UNIQUE,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE data_exports;
@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS citext;
+-- fails if two existing accounts differ only by case; merge or rename one first
+ALTER TABLE users ALTER COLUMN email TYPE CITEXT;
+UPDATE users SET email = LOWER(TRIM(email)) WHERE email::text <> LOWER(TRIM(email));
+
+-- +goose Down
+ALTER TABLE users ALTER COLUMN email TYPE TEXT;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- failed login counters, keyed by "account:<email>" or "ip:<address>"
+CREATE TABLE login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE login_throttles;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE magic_links (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    -- hash of the cookie set on the device that asked for the link
+    device_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE magic_links;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- every refresh replaces the token with a child in the same family;
+-- presenting a token that already has a child revokes the family
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+ADD COLUMN parent_id UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+ADD COLUMN rotated_at TIMESTAMP;
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN family_id DROP DEFAULT;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN rotated_at,
+DROP COLUMN parent_id,
+DROP COLUMN family_id;
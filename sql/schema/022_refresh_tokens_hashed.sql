@@ -0,0 +1,15 @@
+-- +goose Up
+-- only a SHA-256 digest of each refresh token is kept; existing tokens are
+-- converted in place so current sessions keep working
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+-- +goose Down
+-- digests can't be turned back into tokens, so every session is signed out
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
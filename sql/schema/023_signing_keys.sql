@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE signing_keys (
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    alg TEXT NOT NULL,
+    -- private key sealed with a key derived from SECRET_JWT
+    sealed_key TEXT NOT NULL,
+    -- 'active', 'verify' or 'retired'
+    status TEXT NOT NULL,
+    activates_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE signing_keys;
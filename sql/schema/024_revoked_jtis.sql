@@ -0,0 +1,26 @@
+-- +goose Up
+-- access tokens revoked before they expire; rows can go once expires_at has passed
+CREATE TABLE revoked_jtis (
+    jti UUID PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revoked_jtis_revoked_at_idx ON revoked_jtis (revoked_at);
+
+-- the access token issued alongside each refresh token, so revoking a session
+-- or every session of a user can denylist the access tokens too
+ALTER TABLE refresh_tokens
+ADD COLUMN access_jti UUID NOT NULL DEFAULT gen_random_uuid(),
+ADD COLUMN access_expires_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN access_jti DROP DEFAULT,
+ALTER COLUMN access_expires_at DROP DEFAULT;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN access_expires_at,
+DROP COLUMN access_jti;
+
+DROP TABLE revoked_jtis;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE user_identities (
+    -- the OpenID Connect provider and its stable ID for the user
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- as the provider last reported it; Chirpy's own email may have changed since
+    email TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_login_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- who has acted on each notification, so a repeat action doesn't count twice
+CREATE TABLE notification_actors (
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (notification_id, actor_id)
+);
+
+-- only the latest actor of existing notifications is known
+INSERT INTO notification_actors (notification_id, actor_id)
+SELECT id, actor_id FROM notifications
+WHERE actor_id IS NOT NULL;
+
+-- +goose Down
+DROP TABLE notification_actors;